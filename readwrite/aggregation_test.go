@@ -0,0 +1,255 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"math/rand"
+	"strings"
+)
+
+// requireVersion skips the spec when the server is older than version.
+func requireVersion(session *mgo.Session, feature string, version ...int) {
+	info, err := session.BuildInfo()
+	Expect(err).NotTo(HaveOccurred())
+
+	if !info.VersionAtLeast(version...) {
+		v := make([]string, len(version))
+		for i, n := range version {
+			v[i] = fmt.Sprint(n)
+		}
+		Skip(fmt.Sprintf("%s needs MongoDB %s, server is %s", feature, strings.Join(v, "."), info.Version))
+	}
+}
+
+// Server error codes for a blocking stage exceeding the memory limit
+// without allowDiskUse, before and from MongoDB 4.4.
+const (
+	codeSortExceededMemoryLimit = 16819
+	codeExceededMemoryLimit     = 292
+)
+
+// keyTotal is the shape of the grouping results asserted below.
+type keyTotal struct {
+	Key   string `bson:"_id"`
+	Total int    `bson:"total"`
+}
+
+var _ = describeGroup(groupCRUD, "MongoDB aggregation pipelines", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var db *mgo.Database
+	var orders, customers *mgo.Collection
+	var outName = "TestAggregationOut-" + differentiator
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+
+		db = rootSession.DB(config.workDatabase(differentiator))
+		orders = db.C("TestOrders-" + differentiator)
+		customers = db.C("TestCustomers-" + differentiator)
+
+		Expect(customers.Insert(
+			bson.M{"_id": "alice", "city": "Paris"},
+			bson.M{"_id": "bob", "city": "Lyon"},
+			bson.M{"_id": "carol", "city": "Paris"},
+		)).To(Succeed())
+
+		Expect(orders.Insert(
+			bson.M{"_id": 1, "customer": "alice", "total": 25, "items": []bson.M{
+				{"sku": "a", "qty": 2}, {"sku": "b", "qty": 1},
+			}},
+			bson.M{"_id": 2, "customer": "bob", "total": 10, "items": []bson.M{
+				{"sku": "a", "qty": 1},
+			}},
+			bson.M{"_id": 3, "customer": "alice", "total": 21, "items": []bson.M{
+				{"sku": "c", "qty": 3},
+			}},
+			bson.M{"_id": 4, "customer": "carol", "total": 20, "items": []bson.M{
+				{"sku": "b", "qty": 4},
+			}},
+		)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		orders.DropCollection()
+		customers.DropCollection()
+		db.C(outName).DropCollection()
+		rootSession.Close()
+	})
+
+	It("should $match, $group and $sort", func() {
+		var result []keyTotal
+		err := orders.Pipe([]bson.M{
+			{"$match": bson.M{"total": bson.M{"$gte": 15}}},
+			{"$group": bson.M{"_id": "$customer", "total": bson.M{"$sum": "$total"}}},
+			{"$sort": bson.M{"_id": 1}},
+		}).All(&result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal([]keyTotal{{"alice", 46}, {"carol", 20}}))
+	})
+
+	It("should $unwind arrays", func() {
+		var result []keyTotal
+		err := orders.Pipe([]bson.M{
+			{"$unwind": "$items"},
+			{"$group": bson.M{"_id": "$items.sku", "total": bson.M{"$sum": "$items.qty"}}},
+			{"$sort": bson.M{"_id": 1}},
+		}).All(&result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal([]keyTotal{{"a", 3}, {"b", 5}, {"c", 3}}))
+	})
+
+	It("should $lookup another collection", func() {
+		requireVersion(rootSession, "$lookup", 3, 2)
+
+		var result []keyTotal
+		err := orders.Pipe([]bson.M{
+			{"$lookup": bson.M{"from": customers.Name, "localField": "customer", "foreignField": "_id", "as": "customer"}},
+			{"$unwind": "$customer"},
+			{"$group": bson.M{"_id": "$customer.city", "total": bson.M{"$sum": "$total"}}},
+			{"$sort": bson.M{"_id": 1}},
+		}).All(&result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal([]keyTotal{{"Lyon", 10}, {"Paris", 66}}))
+	})
+
+	It("should run several sub-pipelines with $facet", func() {
+		requireVersion(rootSession, "$facet", 3, 4)
+
+		var result struct {
+			BigOrders  []struct{ N int } `bson:"bigOrders"`
+			ByCustomer []keyTotal        `bson:"byCustomer"`
+		}
+		err := orders.Pipe([]bson.M{
+			{"$facet": bson.M{
+				"bigOrders": []bson.M{
+					{"$match": bson.M{"total": bson.M{"$gte": 20}}},
+					{"$count": "n"},
+				},
+				"byCustomer": []bson.M{
+					{"$group": bson.M{"_id": "$customer", "total": bson.M{"$sum": 1}}},
+					{"$sort": bson.M{"_id": 1}},
+				},
+			}},
+		}).One(&result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.BigOrders).To(HaveLen(1))
+		Expect(result.BigOrders[0].N).To(Equal(3))
+		Expect(result.ByCustomer).To(Equal([]keyTotal{{"alice", 2}, {"bob", 1}, {"carol", 1}}))
+	})
+
+	It("should write the results with $out", func() {
+		requireVersion(rootSession, "$out", 2, 6)
+
+		err := orders.Pipe([]bson.M{
+			{"$group": bson.M{"_id": "$customer", "total": bson.M{"$sum": "$total"}}},
+			{"$out": outName},
+		}).Iter().Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		var result []keyTotal
+		Expect(db.C(outName).Find(nil).Sort("_id").All(&result)).To(Succeed())
+		Expect(result).To(Equal([]keyTotal{{"alice", 46}, {"bob", 10}, {"carol", 20}}))
+	})
+
+	It("should merge the results into an existing collection with $merge", func() {
+		requireVersion(rootSession, "$merge", 4, 2)
+
+		Expect(db.C(outName).Insert(bson.M{"_id": "alice", "total": 0}, bson.M{"_id": "dave", "total": 7})).To(Succeed())
+
+		err := orders.Pipe([]bson.M{
+			{"$group": bson.M{"_id": "$customer", "total": bson.M{"$sum": "$total"}}},
+			{"$merge": bson.M{"into": outName, "whenMatched": "replace", "whenNotMatched": "insert"}},
+		}).Iter().Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		var result []keyTotal
+		Expect(db.C(outName).Find(nil).Sort("_id").All(&result)).To(Succeed())
+		Expect(result).To(Equal([]keyTotal{{"alice", 46}, {"bob", 10}, {"carol", 20}, {"dave", 7}}))
+	})
+})
+
+var _ = describeGroup(groupPerformance, "MongoDB aggregation spilling to disk", func() {
+
+	// largeSortMB exceeds the 100MB aggregation memory limit.
+	const largeSortMB = 110
+	const docSize = 16 * 1024
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var col *mgo.Collection
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		col = rootSession.DB(config.workDatabase(differentiator)).C("TestLargeSort-" + differentiator)
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		col.DropCollection()
+		rootSession.Close()
+	})
+
+	It("should sort more than the memory limit with allowDiskUse", func() {
+		requireVersion(rootSession, "allowDiskUse", 2, 6)
+
+		padding := strings.Repeat("x", docSize)
+		count := largeSortMB * 1024 * 1024 / docSize
+		rng := rand.New(rand.NewSource(1))
+
+		batch := make([]interface{}, 0, 100)
+		for i := 0; i < count; i++ {
+			batch = append(batch, bson.M{"key": rng.Int63(), "padding": padding})
+			if len(batch) == cap(batch) || i == count-1 {
+				Expect(col.Insert(batch...)).To(Succeed())
+				batch = batch[:0]
+			}
+		}
+
+		// Using padding after the sort keeps it in the sorted documents,
+		// whereas projecting it out would let the server drop it early.
+		pipeline := []bson.M{
+			{"$sort": bson.M{"key": 1}},
+			{"$project": bson.M{"_id": 0, "key": 1, "padding": bson.M{"$substr": []interface{}{"$padding", 0, 1}}}},
+		}
+
+		// Without allowDiskUse the same sort must hit the memory limit,
+		// otherwise the run below would not exercise spilling.
+		var first bson.M
+		err := col.Pipe(pipeline).Batch(1000).One(&first)
+		if err == nil {
+			Skip("the sort fits in memory or the server spills to disk by default")
+		}
+		qerr, ok := err.(*mgo.QueryError)
+		Expect(ok && (qerr.Code == codeExceededMemoryLimit || qerr.Code == codeSortExceededMemoryLimit)).To(
+			BeTrue(), "expected the sort to exceed the memory limit, got %v", err)
+
+		iter := col.Pipe(pipeline).AllowDiskUse().Batch(1000).Iter()
+
+		var doc struct{ Key int64 }
+		seen, previous := 0, int64(-1)
+		for iter.Next(&doc) {
+			Expect(doc.Key).To(BeNumerically(">=", previous))
+			previous = doc.Key
+			seen++
+		}
+		Expect(iter.Close()).To(Succeed())
+		Expect(seen).To(Equal(count))
+	})
+})
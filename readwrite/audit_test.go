@@ -0,0 +1,345 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// severity ranks audit findings.
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityLow
+	severityMedium
+	severityHigh
+	severityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+func (s severity) String() string {
+	return severityNames[s]
+}
+
+func parseSeverity(name string) (severity, bool) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return severity(i), true
+		}
+	}
+	return 0, false
+}
+
+// defaultWeakPasswords are default or commonly guessed passwords,
+// including the one shipped in example-config.json.
+var defaultWeakPasswords = []string{"toto", "password", "admin", "root", "mongo", "mongodb", "changeme", "secret", "123456", "test"}
+
+// defaultAuthMechanisms are the mechanisms the server may enable.
+var defaultAuthMechanisms = []string{mechanismSCRAMSHA1, mechanismSCRAMSHA256, mechanismX509}
+
+const defaultAuditFailSeverity = severityHigh
+
+// auditFailSeverity returns the severity from which findings fail their
+// spec.
+func (c testConfig) auditFailSeverity() severity {
+	if s, ok := parseSeverity(c.AuditFailSeverity); ok {
+		return s
+	}
+	return defaultAuditFailSeverity
+}
+
+// weakPasswords returns audit_weak_passwords, which replaces the default
+// list when set.
+func (c testConfig) weakPasswords() []string {
+	if len(c.AuditWeakPasswords) > 0 {
+		return c.AuditWeakPasswords
+	}
+	return defaultWeakPasswords
+}
+
+func (c testConfig) auditAuthMechanisms() []string {
+	if len(c.AuditAuthMechanisms) > 0 {
+		return c.AuditAuthMechanisms
+	}
+	return defaultAuthMechanisms
+}
+
+// validateAudit appends the audit option problems to problems.
+func (c testConfig) validateAudit(addf func(format string, args ...interface{})) {
+	if _, ok := parseSeverity(c.AuditFailSeverity); c.AuditFailSeverity != "" && !ok {
+		addf("audit_fail_severity %q is not one of %s", c.AuditFailSeverity, strings.Join(severityNames, ", "))
+	}
+}
+
+// weakPassword reports whether password is empty, equal to username or
+// one of weak, ignoring case.
+func weakPassword(username, password string, weak []string) bool {
+	if password == "" || strings.EqualFold(password, username) {
+		return true
+	}
+	for _, w := range weak {
+		if strings.EqualFold(password, w) {
+			return true
+		}
+	}
+	return false
+}
+
+type finding struct {
+	severity severity
+	check    string
+	message  string
+}
+
+func (f finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(f.severity.String()), f.check, f.message)
+}
+
+// report writes findings to GinkgoWriter and fails the spec with those
+// at or above the audit_fail_severity threshold.
+func report(check string, findings []finding) {
+	if len(findings) == 0 {
+		fmt.Fprintf(GinkgoWriter, "%s: no findings\n", check)
+	}
+
+	threshold := config.auditFailSeverity()
+	var failing []string
+	for _, f := range findings {
+		fmt.Fprintln(GinkgoWriter, f)
+		if f.severity >= threshold {
+			failing = append(failing, f.String())
+		}
+	}
+	if len(failing) > 0 {
+		Fail(fmt.Sprintf("%d finding(s) at or above %s:\n  %s", len(failing), threshold, strings.Join(failing, "\n  ")))
+	}
+}
+
+// normalizeTLSMode strips the SSL or TLS suffix of a server TLS mode,
+// e.g. requireSSL and requireTLS both become require.
+func normalizeTLSMode(mode string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(mode, "SSL"), "TLS")
+}
+
+var _ = Describe("Security audit helpers", func() {
+
+	It("should parse severities and default the threshold", func() {
+		s, ok := parseSeverity("Medium")
+		Expect(ok).To(BeTrue())
+		Expect(s).To(Equal(severityMedium))
+
+		_, ok = parseSeverity("severe")
+		Expect(ok).To(BeFalse())
+
+		Expect(testConfig{}.auditFailSeverity()).To(Equal(severityHigh))
+		Expect(testConfig{AuditFailSeverity: "low"}.auditFailSeverity()).To(Equal(severityLow))
+	})
+
+	It("should report an unknown audit_fail_severity", func() {
+		var problems []string
+		addf := func(format string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+
+		testConfig{AuditFailSeverity: "severe"}.validateAudit(addf)
+		Expect(problems).To(ConsistOf(`audit_fail_severity "severe" is not one of info, low, medium, high, critical`))
+	})
+
+	It("should detect weak passwords", func() {
+		Expect(weakPassword("MongoRoot", "toto", defaultWeakPasswords)).To(BeTrue())
+		Expect(weakPassword("MongoRoot", "ChangeMe", defaultWeakPasswords)).To(BeTrue())
+		Expect(weakPassword("MongoRoot", "mongoroot", defaultWeakPasswords)).To(BeTrue())
+		Expect(weakPassword("MongoRoot", "", defaultWeakPasswords)).To(BeTrue())
+		Expect(weakPassword("MongoRoot", "k3Yq-92fj-Lw0p", defaultWeakPasswords)).To(BeFalse())
+		Expect(testConfig{AuditWeakPasswords: []string{"hunter2"}}.weakPasswords()).To(Equal([]string{"hunter2"}))
+	})
+
+	It("should normalize TLS modes", func() {
+		Expect(normalizeTLSMode("requireSSL")).To(Equal("require"))
+		Expect(normalizeTLSMode("preferTLS")).To(Equal("prefer"))
+		Expect(normalizeTLSMode("disabled")).To(Equal("disabled"))
+	})
+})
+
+var _ = describeGroup(groupAudit, "MongoDB security audit", func() {
+
+	It("should only let unauthenticated clients run isMaster", func() {
+		anonymous, err := config.dialAnonymous()
+		Expect(err).NotTo(HaveOccurred())
+		defer anonymous.Close()
+
+		var isMaster bson.M
+		Expect(anonymous.Run("isMaster", &isMaster)).To(Succeed())
+
+		// The severity reflects what each command discloses. buildInfo
+		// is open to unauthenticated clients by design, hence only
+		// informational.
+		probes := []struct {
+			command  interface{}
+			name     string
+			severity severity
+		}{
+			{"buildInfo", "buildInfo", severityInfo},
+			{"serverStatus", "serverStatus", severityMedium},
+			{"hostInfo", "hostInfo", severityMedium},
+			{"replSetGetStatus", "replSetGetStatus", severityMedium},
+			{"listDatabases", "listDatabases", severityHigh},
+			{"getCmdLineOpts", "getCmdLineOpts", severityHigh},
+			{bson.M{"getLog": "global"}, "getLog", severityHigh},
+		}
+
+		var findings []finding
+		for _, probe := range probes {
+			var result bson.M
+			if err := anonymous.Run(probe.command, &result); !unauthorized(err) {
+				findings = append(findings, finding{probe.severity, "unauthenticated access",
+					fmt.Sprintf("%s is not refused (error: %v)", probe.name, err)})
+			}
+		}
+
+		var user bson.M
+		if err := anonymous.DB("admin").C("system.users").Find(nil).One(&user); !unauthorized(err) {
+			findings = append(findings, finding{severityCritical, "unauthenticated access",
+				fmt.Sprintf("admin.system.users is readable (error: %v)", err)})
+		}
+
+		report("unauthenticated access", findings)
+	})
+
+	It("should refuse plaintext connections on every member when TLS is required", func() {
+		if !config.MongoTLSRequired {
+			Skip("mongo_tls_required is not set")
+		}
+
+		var findings []finding
+		for _, addr := range config.addrs() {
+			accepted, err := config.plaintextAccepted(addr)
+			Expect(err).NotTo(HaveOccurred())
+			if accepted {
+				findings = append(findings, finding{severityCritical, "TLS enforcement",
+					fmt.Sprintf("%s accepts plaintext connections", addr)})
+			}
+		}
+
+		report("TLS enforcement", findings)
+	})
+
+	It("should not use a weak root password", func() {
+		if config.MongoAuthMechanism == mechanismX509 {
+			Skip("the root user authenticates with a client certificate")
+		}
+
+		var findings []finding
+		if weakPassword(config.MongoRoot, config.MongoRootPassword, config.weakPasswords()) {
+			findings = append(findings, finding{severityHigh, "root password",
+				fmt.Sprintf("the password of %s is empty, equal to the username or in the weak password list", config.MongoRoot)})
+		}
+
+		report("root password", findings)
+	})
+
+	It("should not expose the HTTP interface", func() {
+		client := &http.Client{Timeout: config.timeouts().Dial}
+
+		var findings []finding
+		for _, addr := range config.addrs() {
+			host, port, err := net.SplitHostPort(addr)
+			Expect(err).NotTo(HaveOccurred())
+			p, err := strconv.Atoi(port)
+			Expect(err).NotTo(HaveOccurred())
+
+			// The HTTP status interface listens on the MongoDB port + 1000.
+			url := "http://" + net.JoinHostPort(host, strconv.Itoa(p+1000)) + "/"
+			resp, err := client.Get(url)
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+			findings = append(findings, finding{severityHigh, "HTTP interface",
+				fmt.Sprintf("%s answers with %s", url, resp.Status)})
+		}
+
+		report("HTTP interface", findings)
+	})
+
+	Context("When reading the server parameters", func() {
+
+		var rootSession *mgo.Session
+		var err error
+
+		BeforeEach(func() {
+			if config.boundCredentials() {
+				Skip("getParameter needs root credentials, mongo_database is set")
+			}
+			rootSession, err = config.dial()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if rootSession != nil {
+				rootSession.Close()
+			}
+		})
+
+		It("should match the authentication mechanism and TLS mode policy", func() {
+			var findings []finding
+
+			var mechanisms struct {
+				AuthenticationMechanisms []string `bson:"authenticationMechanisms"`
+			}
+			err := rootSession.Run(bson.D{{Name: "getParameter", Value: 1}, {Name: "authenticationMechanisms", Value: 1}}, &mechanisms)
+			Expect(err).NotTo(HaveOccurred())
+
+			allowed := map[string]bool{}
+			for _, m := range config.auditAuthMechanisms() {
+				allowed[m] = true
+			}
+			for _, m := range mechanisms.AuthenticationMechanisms {
+				if !allowed[m] {
+					findings = append(findings, finding{severityMedium, "authentication mechanisms",
+						fmt.Sprintf("%s is enabled but not allowed by policy (%s)", m, strings.Join(config.auditAuthMechanisms(), ", "))})
+				}
+			}
+
+			// tlsMode replaces sslMode from MongoDB 4.2.
+			var modes struct {
+				TLSMode string `bson:"tlsMode"`
+				SSLMode string `bson:"sslMode"`
+			}
+			err = rootSession.Run(bson.D{{Name: "getParameter", Value: 1}, {Name: "tlsMode", Value: 1}}, &modes)
+			if err != nil {
+				err = rootSession.Run(bson.D{{Name: "getParameter", Value: 1}, {Name: "sslMode", Value: 1}}, &modes)
+			}
+			Expect(err).NotTo(HaveOccurred())
+			mode := modes.TLSMode
+			if mode == "" {
+				mode = modes.SSLMode
+			}
+
+			switch normalized := normalizeTLSMode(mode); {
+			case config.MongoTLSRequired && normalized != "require":
+				findings = append(findings, finding{severityHigh, "TLS mode",
+					fmt.Sprintf("mongo_tls_required is set but the server runs with %s", mode)})
+			case config.MongoTLS && normalized == "disabled":
+				findings = append(findings, finding{severityHigh, "TLS mode",
+					"mongo_tls is set but the server has TLS disabled"})
+			case !config.MongoTLS && normalized == "disabled":
+				findings = append(findings, finding{severityMedium, "TLS mode",
+					"the server has TLS disabled, traffic is sent in plaintext"})
+			case normalized != "require":
+				findings = append(findings, finding{severityInfo, "TLS mode",
+					fmt.Sprintf("the server runs with %s and accepts plaintext connections", mode)})
+			}
+
+			report("authentication mechanisms and TLS mode", findings)
+		})
+	})
+})
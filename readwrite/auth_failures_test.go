@@ -0,0 +1,184 @@
+package readwrite_test
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+)
+
+// codeAuthenticationFailed is returned for bad credentials by servers
+// that report a code at all.
+const codeAuthenticationFailed = 18
+
+// Classes of access failures.
+const (
+	failureAuthentication = "authentication"
+	failureAuthorization  = "authorization"
+)
+
+// accessFailure classifies err as an authentication failure (the
+// credentials were rejected), an authorization failure (the user lacks
+// a privilege), or "" for anything else.
+func accessFailure(err error) string {
+	if err == nil {
+		return ""
+	}
+	if unauthorized(err) {
+		return failureAuthorization
+	}
+	if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == codeAuthenticationFailed {
+		return failureAuthentication
+	}
+
+	// mgo reports failed logins as plain errors carrying the server message.
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, "auth fail") || strings.Contains(message, "authentication failed") {
+		return failureAuthentication
+	}
+	return ""
+}
+
+var _ = Describe("Access failure classification", func() {
+
+	It("should classify rejected credentials as authentication failures", func() {
+		Expect(accessFailure(&mgo.QueryError{Code: codeAuthenticationFailed, Message: "Authentication failed."})).To(Equal(failureAuthentication))
+		Expect(accessFailure(errors.New("server returned error on SASL authentication step: Authentication failed."))).To(Equal(failureAuthentication))
+		Expect(accessFailure(errors.New("auth fails"))).To(Equal(failureAuthentication))
+	})
+
+	It("should classify missing privileges as authorization failures", func() {
+		Expect(accessFailure(&mgo.QueryError{Code: codeUnauthorized, Message: "not authorized on test to execute command"})).To(Equal(failureAuthorization))
+		Expect(accessFailure(&mgo.LastError{Err: "not authorized for insert on test.c"})).To(Equal(failureAuthorization))
+	})
+
+	It("should leave other errors unclassified", func() {
+		Expect(accessFailure(nil)).To(BeEmpty())
+		Expect(accessFailure(mgo.ErrNotFound)).To(BeEmpty())
+		Expect(accessFailure(&mgo.QueryError{Code: codeCommandNotFound, Message: "no such cmd"})).To(BeEmpty())
+	})
+})
+
+var _ = describeGroup(groupAuth, "MongoDB access control failures", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if rootSession != nil {
+			rootSession.Close()
+		}
+	})
+
+	Context("When a password user is provisioned", func() {
+
+		var databaseName = "TestDatabase-" + differentiator
+		var db *mgo.Database
+		var removed bool
+
+		var user = mgo.User{
+			Username: "TestFailureUser" + differentiator,
+			Password: "TestPassword",
+			Roles:    []mgo.Role{mgo.RoleReadWrite},
+		}
+
+		// newSession returns a session sharing the root connection
+		// parameters but holding no credentials.
+		newSession := func() *mgo.Session {
+			session := rootSession.New()
+			session.LogoutAll()
+			return session
+		}
+
+		BeforeEach(func() {
+			db = nil
+			removed = false
+			if config.boundCredentials() {
+				Skip("creating users needs root credentials, mongo_database is set")
+			}
+
+			db = rootSession.DB(databaseName)
+			Expect(db.UpsertUser(&user)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			if db == nil || removed {
+				return
+			}
+			Expect(db.RemoveUser(user.Username)).To(Succeed())
+		})
+
+		It("should reject a wrong password as an authentication failure", func() {
+			session := newSession()
+			defer session.Close()
+
+			err := config.login(session, databaseName, user.Username, user.Password+"-wrong")
+			Expect(err).To(HaveOccurred())
+			Expect(accessFailure(err)).To(Equal(failureAuthentication), "unexpected error %v", err)
+
+			users, err := authenticatedAs(session)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(users).To(BeEmpty())
+		})
+
+		It("should reject a removed user as an authentication failure", func() {
+			session := newSession()
+			Expect(config.login(session, databaseName, user.Username, user.Password)).To(Succeed())
+			session.Close()
+
+			Expect(db.RemoveUser(user.Username)).To(Succeed())
+			removed = true
+
+			session = newSession()
+			defer session.Close()
+			err := config.login(session, databaseName, user.Username, user.Password)
+			Expect(err).To(HaveOccurred())
+			Expect(accessFailure(err)).To(Equal(failureAuthentication), "unexpected error %v", err)
+		})
+	})
+
+	Context("When the session is not authenticated", func() {
+
+		var anonymous *mgo.Session
+		var col *mgo.Collection
+
+		BeforeEach(func() {
+			anonymous = nil
+			col = rootSession.DB(config.workDatabase(differentiator)).C("TestCollection-" + differentiator)
+			Expect(col.Insert(bson.M{"n": 1})).To(Succeed())
+
+			anonymous, err = config.dialAnonymous()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if anonymous != nil {
+				anonymous.Close()
+			}
+			col.DropCollection()
+		})
+
+		It("should not list databases", func() {
+			_, err := anonymous.DatabaseNames()
+			Expect(err).To(HaveOccurred(), "the server lists databases without authentication")
+			Expect(accessFailure(err)).To(Equal(failureAuthorization), "unexpected error %v", err)
+		})
+
+		It("should not read the test collection", func() {
+			var doc bson.M
+			err := anonymous.DB(col.Database.Name).C(col.Name).Find(nil).One(&doc)
+			Expect(err).To(HaveOccurred(), "the server serves documents without authentication")
+			Expect(accessFailure(err)).To(Equal(failureAuthorization), "unexpected error %v", err)
+		})
+	})
+})
@@ -0,0 +1,194 @@
+package readwrite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+)
+
+const (
+	mechanismSCRAMSHA1   = "SCRAM-SHA-1"
+	mechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	mechanismMongoCR     = "MONGODB-CR"
+	mechanismX509        = "MONGODB-X509"
+	mechanismPlain       = "PLAIN"
+)
+
+// supportedAuthMechanisms lists the mechanisms mgo implements without the
+// sasl build tag. SCRAM-SHA-256 and GSSAPI are not among them: mgo hands
+// any other mechanism to its cgo SASL client.
+var supportedAuthMechanisms = []string{mechanismSCRAMSHA1, mechanismMongoCR, mechanismX509, mechanismPlain}
+
+// externalMechanism reports whether credentials for mechanism live
+// outside MongoDB, in the $external database.
+func externalMechanism(mechanism string) bool {
+	return mechanism == mechanismX509 || mechanism == mechanismPlain
+}
+
+// authSource returns the database the root credentials are checked
+// against, defaulting to $external for external mechanisms.
+func (c testConfig) authSource() string {
+	if c.MongoAuthSource == "" && externalMechanism(c.MongoAuthMechanism) {
+		return "$external"
+	}
+	return c.MongoAuthSource
+}
+
+// userMechanism returns the mechanism used by the password users the
+// suite provisions: the configured one unless it is external.
+func (c testConfig) userMechanism() string {
+	if externalMechanism(c.MongoAuthMechanism) {
+		return ""
+	}
+	return c.MongoAuthMechanism
+}
+
+// login authenticates session as a user provisioned by the suite in db,
+// using the configured mechanism.
+func (c testConfig) login(session *mgo.Session, db, username, password string) error {
+	return session.Login(&mgo.Credential{
+		Username:  username,
+		Password:  password,
+		Source:    db,
+		Mechanism: c.userMechanism(),
+	})
+}
+
+// validateAuth appends the authentication option problems to problems.
+func (c testConfig) validateAuth(addf func(format string, args ...interface{})) {
+	if c.MongoRoot == "" {
+		addf("mongo_root_username is required")
+	}
+
+	supported := c.MongoAuthMechanism == ""
+	for _, m := range supportedAuthMechanisms {
+		supported = supported || c.MongoAuthMechanism == m
+	}
+	if c.MongoAuthMechanism == mechanismSCRAMSHA256 {
+		addf("mongo_auth_mechanism %s is not supported by the mgo driver; users created on MongoDB 4.0+ "+
+			"hold both SCRAM credentials by default, use %s unless the server disables it",
+			mechanismSCRAMSHA256, mechanismSCRAMSHA1)
+	} else if !supported {
+		addf("mongo_auth_mechanism %q is not supported by the mgo driver (supported: %s)",
+			c.MongoAuthMechanism, strings.Join(supportedAuthMechanisms, ", "))
+	}
+
+	if c.MongoAuthMechanism == mechanismX509 {
+		if c.MongoRootPassword != "" {
+			addf("mongo_root_password must be empty with %s, the client certificate authenticates", mechanismX509)
+		}
+		if !c.MongoTLS || c.MongoTLSCertFile == "" {
+			addf("%s needs mongo_tls and mongo_tls_cert_file", mechanismX509)
+		}
+	} else if c.MongoRootPassword == "" {
+		addf("mongo_root_password is required")
+	}
+}
+
+type connectionStatus struct {
+	AuthInfo struct {
+		AuthenticatedUsers []struct {
+			User string `bson:"user"`
+			DB   string `bson:"db"`
+		} `bson:"authenticatedUsers"`
+	} `bson:"authInfo"`
+}
+
+// authenticatedAs returns the "user@db" identities session is logged in as.
+func authenticatedAs(session *mgo.Session) ([]string, error) {
+	var status connectionStatus
+	if err := session.Run("connectionStatus", &status); err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for _, u := range status.AuthInfo.AuthenticatedUsers {
+		users = append(users, u.User+"@"+u.DB)
+	}
+	return users, nil
+}
+
+var _ = describeGroup(groupAuth, "MongoDB authentication mechanisms", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+
+	mechanismName := func(mechanism string) string {
+		if mechanism == "" {
+			return "the server default mechanism"
+		}
+		return mechanism
+	}
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if rootSession != nil {
+			rootSession.Close()
+		}
+	})
+
+	It("should authenticate the configured user with the configured mechanism", func() {
+		users, err := authenticatedAs(rootSession)
+		Expect(err).NotTo(HaveOccurred())
+
+		source := config.authSource()
+		if source == "" {
+			source = "admin"
+		}
+		Expect(users).To(ContainElement(config.MongoRoot+"@"+source),
+			"expected %s to authenticate with %s", config.MongoRoot, mechanismName(config.MongoAuthMechanism))
+	})
+
+	Context("When a password user is provisioned", func() {
+
+		var databaseName = "TestDatabase-" + differentiator
+		var db *mgo.Database
+
+		var user = mgo.User{
+			Username: "TestMechanismUser" + differentiator,
+			Password: "TestPassword",
+			Roles:    []mgo.Role{mgo.RoleRead},
+		}
+
+		BeforeEach(func() {
+			db = nil
+			if config.boundCredentials() {
+				Skip("creating users needs root credentials, mongo_database is set")
+			}
+
+			db = rootSession.DB(databaseName)
+			Expect(db.UpsertUser(&user)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			if db == nil {
+				return
+			}
+			Expect(db.RemoveUser(user.Username)).To(Succeed())
+		})
+
+		It("should authenticate it against its own database", func() {
+			session := rootSession.New()
+			defer session.Close()
+			session.LogoutAll()
+
+			err := config.login(session, databaseName, user.Username, user.Password)
+			Expect(err).NotTo(HaveOccurred(), "login with %s", mechanismName(config.userMechanism()))
+
+			users, err := authenticatedAs(session)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(users).To(ConsistOf(user.Username + "@" + databaseName))
+
+			Expect(session.DB(databaseName).C("TestCollection").Find(bson.M{}).Count()).To(Equal(0))
+		})
+	})
+})
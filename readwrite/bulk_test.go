@@ -0,0 +1,109 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var _ = describeGroup(groupCRUD, "MongoDB bulk writes", func() {
+
+	const batchSize = 1000
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var col *mgo.Collection
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		col = rootSession.DB(config.workDatabase(differentiator)).C("TestBulk-" + differentiator)
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		col.DropCollection()
+		rootSession.Close()
+	})
+
+	It("should run inserts, upserts, updates and removes in a single batch", func() {
+		bulk := col.Bulk()
+
+		docs := make([]interface{}, batchSize)
+		for i := range docs {
+			docs[i] = bson.M{"_id": i, "n": i}
+		}
+		bulk.Insert(docs...)
+
+		// Half of the upserts match the last inserted documents, half
+		// insert new ones without an "n" field.
+		for i := batchSize - 5; i < batchSize+5; i++ {
+			bulk.Upsert(bson.M{"_id": i}, bson.M{"$set": bson.M{"upserted": true}})
+		}
+		bulk.UpdateAll(bson.M{"n": bson.M{"$lt": 100}}, bson.M{"$set": bson.M{"low": true}})
+		bulk.RemoveAll(bson.M{"n": bson.M{"$gte": batchSize - 50}})
+
+		result, err := bulk.Run()
+		Expect(err).NotTo(HaveOccurred())
+		fmt.Fprintf(GinkgoWriter, "bulk result: matched %d, modified %d\n", result.Matched, result.Modified)
+
+		// 10 upserts (5 matched, 5 inserted) + 100 updates + 50 removes.
+		Expect(result.Matched).To(Equal(160))
+		// 5 upserted existing documents + 100 updates.
+		Expect(result.Modified).To(Equal(105))
+
+		Expect(col.Count()).To(Equal(batchSize + 5 - 50))
+		Expect(col.Find(bson.M{"low": true}).Count()).To(Equal(100))
+		Expect(col.Find(bson.M{"upserted": true}).Count()).To(Equal(5))
+	})
+
+	Context("When a duplicate key is in the middle of the batch", func() {
+
+		// runWithDuplicate inserts ten documents whose sixth reuses the
+		// _id of the third, then updates the first one.
+		runWithDuplicate := func(bulk *mgo.Bulk) *mgo.BulkError {
+			for i := 0; i < 10; i++ {
+				id := i
+				if i == 5 {
+					id = 2
+				}
+				bulk.Insert(bson.M{"_id": id})
+			}
+			bulk.Update(bson.M{"_id": 0}, bson.M{"$set": bson.M{"updated": true}})
+
+			_, err := bulk.Run()
+			Expect(err).To(HaveOccurred())
+			bulkErr, ok := err.(*mgo.BulkError)
+			Expect(ok).To(BeTrue(), "unexpected error %#v", err)
+
+			cases := bulkErr.Cases()
+			Expect(cases).To(HaveLen(1))
+			Expect(cases[0].Index).To(Equal(5))
+			Expect(mgo.IsDup(cases[0].Err)).To(BeTrue(), "unexpected error %v", cases[0].Err)
+			return bulkErr
+		}
+
+		It("should stop at the failure in ordered mode", func() {
+			runWithDuplicate(col.Bulk())
+
+			Expect(col.Count()).To(Equal(5))
+			Expect(col.Find(bson.M{"updated": true}).Count()).To(Equal(0))
+		})
+
+		It("should carry on past the failure in unordered mode", func() {
+			bulk := col.Bulk()
+			bulk.Unordered()
+			runWithDuplicate(bulk)
+
+			Expect(col.Count()).To(Equal(9))
+			Expect(col.Find(bson.M{"updated": true}).Count()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,615 @@
+package readwrite_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix prefixes the environment variables overriding testConfig
+// fields, e.g. MONGO_SMOKE_MONGO_HOST for mongo_host.
+const envPrefix = "MONGO_SMOKE_"
+
+type testConfig struct {
+	TimeoutScale           float64  `json:"timeout_scale" yaml:"timeout_scale"`
+	MongoURI               string   `json:"mongo_uri" yaml:"mongo_uri"`
+	MongoHost              string   `json:"mongo_host" yaml:"mongo_host"`
+	MongoHosts             []string `json:"mongo_hosts" yaml:"mongo_hosts"`
+	MongoPort              string   `json:"mongo_port" yaml:"mongo_port"`
+	MongoRoot              string   `json:"mongo_root_username" yaml:"mongo_root_username"`
+	MongoRootPassword      string   `json:"mongo_root_password" yaml:"mongo_root_password"`
+	MongoAuthSource        string   `json:"mongo_auth_source" yaml:"mongo_auth_source"`
+	MongoAuthMechanism     string   `json:"mongo_auth_mechanism" yaml:"mongo_auth_mechanism"`
+	MongoReplicaSetName    string   `json:"mongo_replica_set_name" yaml:"mongo_replica_set_name"`
+	MongoReplicaSetMembers int      `json:"mongo_replica_set_members" yaml:"mongo_replica_set_members"`
+	MongoTLS               bool     `json:"mongo_tls" yaml:"mongo_tls"`
+	MongoTLSRequired       bool     `json:"mongo_tls_required" yaml:"mongo_tls_required"`
+	MongoTLSCAFile         string   `json:"mongo_tls_ca_file" yaml:"mongo_tls_ca_file"`
+	MongoTLSCertFile       string   `json:"mongo_tls_cert_file" yaml:"mongo_tls_cert_file"`
+	MongoTLSKeyFile        string   `json:"mongo_tls_key_file" yaml:"mongo_tls_key_file"`
+	MongoTLSServerName     string   `json:"mongo_tls_server_name" yaml:"mongo_tls_server_name"`
+	MongoTLSInsecure       bool     `json:"mongo_tls_insecure_skip_verify" yaml:"mongo_tls_insecure_skip_verify"`
+	MongoConnectTimeoutMS  int      `json:"mongo_connect_timeout_ms" yaml:"mongo_connect_timeout_ms"`
+	MongoSocketTimeoutMS   int      `json:"mongo_socket_timeout_ms" yaml:"mongo_socket_timeout_ms"`
+	MongoDatabase          string   `json:"mongo_database" yaml:"mongo_database"`
+	MongoService           string   `json:"mongo_service" yaml:"mongo_service"`
+	FailoverMaxElectionMS  int      `json:"failover_max_election_ms" yaml:"failover_max_election_ms"`
+	MaxReplicationLagMS    int      `json:"max_replication_lag_ms" yaml:"max_replication_lag_ms"`
+	GridFSFileSizeKB       int      `json:"gridfs_file_size_kb" yaml:"gridfs_file_size_kb"`
+	AuditWeakPasswords     []string `json:"audit_weak_passwords" yaml:"audit_weak_passwords"`
+	AuditAuthMechanisms    []string `json:"audit_auth_mechanisms" yaml:"audit_auth_mechanisms"`
+	AuditFailSeverity      string   `json:"audit_fail_severity" yaml:"audit_fail_severity"`
+
+	Profile string          `json:"profile" yaml:"profile"`
+	Groups  map[string]bool `json:"groups" yaml:"groups"`
+}
+
+// loadConfig reads the JSON or YAML file at path and the MONGO_SMOKE_*
+// environment overrides, then applies in order the MongoDB service bound
+// in VCAP_SERVICES and mongo_uri (or the MONGO_URI environment variable,
+// which wins). The file may be omitted when any of those environment
+// variables is set. The result is validated before being returned.
+func loadConfig(path string) (cfg testConfig, err error) {
+	uri := os.Getenv("MONGO_URI")
+	vcap := os.Getenv("VCAP_SERVICES")
+
+	if path == "" && uri == "" && vcap == "" && !hasEnvOverrides() {
+		return cfg, errors.New("CONFIG_PATH is not set and no configuration is given through the environment")
+	}
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err = decodeConfig(path, data, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err = cfg.applyEnvOverrides(os.LookupEnv); err != nil {
+		return cfg, err
+	}
+
+	if vcap != "" {
+		service, err := findVCAPService(vcap, cfg.MongoService)
+		if err == nil {
+			err = cfg.applyVCAPService(service)
+		} else if cfg.MongoService == "" && (path != "" || uri != "" || hasEnvOverrides()) {
+			// On Cloud Foundry VCAP_SERVICES is always set. Without a
+			// MongoDB binding it is ignored when the configuration comes
+			// from elsewhere and no service was asked for.
+			err = nil
+		}
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	if uri != "" {
+		cfg.MongoURI = uri
+	}
+	if cfg.MongoURI != "" {
+		if err = cfg.applyMongoURI(cfg.MongoURI); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.validate()
+}
+
+// configError aggregates every problem found by validate.
+type configError []string
+
+func (e configError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// validate checks the fields the suite relies on, so that a bad value is
+// reported as such rather than as a confusing dial failure.
+func (c testConfig) validate() error {
+	var problems configError
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.MongoHost != "" && len(c.MongoHosts) > 0 {
+		addf("mongo_host and mongo_hosts are mutually exclusive")
+	}
+	if c.MongoHost == "" && len(c.MongoHosts) == 0 {
+		addf("no host given: set mongo_hosts, mongo_host or mongo_uri")
+	}
+
+	needsPort := false
+	hosts := append([]string{c.MongoHost}, c.MongoHosts...)
+	if c.MongoHost == "" {
+		hosts = hosts[1:]
+	}
+	for _, h := range hosts {
+		host, port, err := net.SplitHostPort(h)
+		if err != nil {
+			host, port = strings.TrimSuffix(strings.TrimPrefix(h, "["), "]"), ""
+			needsPort = true
+		}
+		if host == "" || strings.ContainsAny(host, " /\t@,[]") {
+			addf("invalid host %q", h)
+		}
+		if port != "" && !validPort(port) {
+			addf("invalid port %q in host %q", port, h)
+		}
+	}
+	if (needsPort || c.MongoPort != "") && !validPort(c.MongoPort) {
+		addf("mongo_port must be a number between 1 and 65535, got %q", c.MongoPort)
+	}
+
+	c.validateAuth(addf)
+	c.validateTLS(addf)
+	c.validateGroups(addf)
+	c.validateAudit(addf)
+
+	if c.MongoReplicaSetMembers < 0 {
+		addf("mongo_replica_set_members must not be negative")
+	}
+	if c.MongoReplicaSetMembers > 0 && c.MongoReplicaSetName == "" {
+		addf("mongo_replica_set_members needs mongo_replica_set_name")
+	}
+	if c.TimeoutScale < 0 {
+		addf("timeout_scale must not be negative, got %g", c.TimeoutScale)
+	}
+	if c.MongoConnectTimeoutMS < 0 {
+		addf("mongo_connect_timeout_ms must not be negative")
+	}
+	if c.MongoSocketTimeoutMS < 0 {
+		addf("mongo_socket_timeout_ms must not be negative")
+	}
+	if c.FailoverMaxElectionMS < 0 {
+		addf("failover_max_election_ms must not be negative")
+	}
+	if c.MaxReplicationLagMS < 0 {
+		addf("max_replication_lag_ms must not be negative")
+	}
+	if c.GridFSFileSizeKB < 0 {
+		addf("gridfs_file_size_kb must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// decodeConfig decodes data as YAML when path has a .yml or .yaml
+// extension, as JSON for .json, and otherwise guesses from the content.
+func decodeConfig(path string, data []byte, cfg *testConfig) error {
+	isJSON := bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		isJSON = false
+	case ".json":
+		isJSON = true
+	}
+
+	if isJSON {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("%s: invalid JSON: %s", path, err)
+		}
+		return nil
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("%s: invalid YAML: %s", path, err)
+	}
+	return nil
+}
+
+func hasEnvOverrides() bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnvOverrides sets every field whose MONGO_SMOKE_<JSON NAME>
+// variable is defined. A variable set to the empty string clears the
+// field. Lists are comma-separated, and so are the name=true|false pairs
+// of groups.
+func (c *testConfig) applyEnvOverrides(lookupEnv func(string) (string, bool)) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		key := envPrefix + strings.ToUpper(name)
+		value, ok := lookupEnv(key)
+		if !ok {
+			continue
+		}
+
+		field := v.Field(i)
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false", key)
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer", key)
+			}
+			field.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number", key)
+			}
+			field.SetFloat(f)
+		case reflect.Map:
+			groups := map[string]bool{}
+			for _, item := range strings.Split(value, ",") {
+				parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+				if len(parts) != 2 {
+					return fmt.Errorf("%s must be a list of name=true|false", key)
+				}
+				b, err := strconv.ParseBool(parts[1])
+				if err != nil {
+					return fmt.Errorf("%s must be a list of name=true|false", key)
+				}
+				groups[parts[0]] = b
+			}
+			field.Set(reflect.ValueOf(groups))
+		case reflect.Slice:
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("%s: unsupported field type %s", key, field.Type())
+		}
+	}
+	return nil
+}
+
+// addrs returns the seed list to dial. mongo_hosts takes precedence over
+// mongo_host; entries without an explicit port get mongo_port. IPv6
+// literals must be bracketed when they carry a port, e.g. "[::1]:27017".
+func (c testConfig) addrs() []string {
+	hosts := c.MongoHosts
+	if len(hosts) == 0 {
+		hosts = []string{c.MongoHost}
+	}
+
+	addrs := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if host, port, err := net.SplitHostPort(h); err == nil {
+			addrs = append(addrs, net.JoinHostPort(host, port))
+			continue
+		}
+		host := strings.TrimSuffix(strings.TrimPrefix(h, "["), "]")
+		addrs = append(addrs, net.JoinHostPort(host, c.MongoPort))
+	}
+	return addrs
+}
+
+// boundCredentials reports whether the configured user is confined to
+// mongo_database, as service binding credentials are, rather than being
+// able to provision databases and users itself.
+func (c testConfig) boundCredentials() bool {
+	return c.MongoDatabase != ""
+}
+
+// workDatabase returns the database specs write to: the bound database
+// when the credentials are confined to one, otherwise the per-run
+// TestDatabase-<differentiator> the suite provisions and drops.
+func (c testConfig) workDatabase(differentiator string) string {
+	if c.boundCredentials() {
+		return c.MongoDatabase
+	}
+	return "TestDatabase-" + differentiator
+}
+
+// dialInfo builds the root connection parameters shared by every spec.
+func (c testConfig) dialInfo() (*mgo.DialInfo, error) {
+	info := &mgo.DialInfo{
+		Addrs:          c.addrs(),
+		ReplicaSetName: c.MongoReplicaSetName,
+		Source:         c.authSource(),
+		Mechanism:      c.MongoAuthMechanism,
+		Username:       c.MongoRoot,
+		Password:       c.MongoRootPassword,
+		Timeout:        c.timeouts().Dial,
+	}
+
+	if c.MongoTLS {
+		dialServer, err := c.tlsDialer()
+		if err != nil {
+			return nil, err
+		}
+		info.DialServer = dialServer
+	}
+
+	return info, nil
+}
+
+// dial opens a root session with the configured connection parameters
+// and scaled timeouts.
+func (c testConfig) dial() (*mgo.Session, error) {
+	info, err := c.dialInfo()
+	if err != nil {
+		return nil, err
+	}
+	return c.dialWithInfo(info)
+}
+
+// dialDirect opens a session to the single member at addr only, which may
+// be read from whatever its replica set state.
+func (c testConfig) dialDirect(addr string) (*mgo.Session, error) {
+	info, err := c.dialInfo()
+	if err != nil {
+		return nil, err
+	}
+	info.Addrs = []string{addr}
+	info.Direct = true
+
+	session, err := c.dialWithInfo(info)
+	if err != nil {
+		return nil, err
+	}
+	session.SetMode(mgo.Monotonic, true)
+	return session, nil
+}
+
+// dialAnonymous opens a session with the configured connection
+// parameters but without authenticating.
+func (c testConfig) dialAnonymous() (*mgo.Session, error) {
+	info, err := c.dialInfo()
+	if err != nil {
+		return nil, err
+	}
+	info.Username, info.Password, info.Source, info.Mechanism = "", "", "", ""
+	return c.dialWithInfo(info)
+}
+
+func (c testConfig) dialWithInfo(info *mgo.DialInfo) (*mgo.Session, error) {
+	session, err := mgo.DialWithInfo(info)
+	if err != nil {
+		return nil, err
+	}
+
+	t := c.timeouts()
+	session.SetSocketTimeout(t.Socket)
+	session.SetSyncTimeout(t.Sync)
+	return session, nil
+}
+
+var _ = Describe("Configuration", func() {
+
+	// lookup serves environment variables from env, like os.LookupEnv.
+	lookup := func(env map[string]string) func(string) (string, bool) {
+		return func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		}
+	}
+
+	const jsonConfig = `{"mongo_hosts": ["h1", "h2"], "mongo_port": "27017", "timeout_scale": 2}`
+	const yamlConfig = "mongo_hosts:\n  - h1\n  - h2\nmongo_port: \"27017\"\ntimeout_scale: 2\n"
+
+	expected := testConfig{MongoHosts: []string{"h1", "h2"}, MongoPort: "27017", TimeoutScale: 2}
+
+	It("should decode JSON and YAML files by extension", func() {
+		var fromJSON, fromYAML testConfig
+		Expect(decodeConfig("config.json", []byte(jsonConfig), &fromJSON)).To(Succeed())
+		Expect(decodeConfig("config.yml", []byte(yamlConfig), &fromYAML)).To(Succeed())
+		Expect(fromJSON).To(Equal(expected))
+		Expect(fromYAML).To(Equal(expected))
+	})
+
+	It("should detect the format from the content otherwise", func() {
+		var fromJSON, fromYAML testConfig
+		Expect(decodeConfig("config", []byte(jsonConfig), &fromJSON)).To(Succeed())
+		Expect(decodeConfig("config", []byte(yamlConfig), &fromYAML)).To(Succeed())
+		Expect(fromJSON).To(Equal(expected))
+		Expect(fromYAML).To(Equal(expected))
+	})
+
+	It("should name the file and format on decoding errors", func() {
+		var cfg testConfig
+		Expect(decodeConfig("config.yaml", []byte("mongo_hosts: [h1"), &cfg)).To(
+			MatchError(ContainSubstring("config.yaml: invalid YAML")))
+	})
+
+	It("should apply environment overrides to every field type", func() {
+		env := map[string]string{
+			"MONGO_SMOKE_MONGO_HOSTS":             "h3, h4",
+			"MONGO_SMOKE_MONGO_ROOT_PASSWORD":     "secret",
+			"MONGO_SMOKE_MONGO_TLS":               "true",
+			"MONGO_SMOKE_MONGO_SOCKET_TIMEOUT_MS": "500",
+			"MONGO_SMOKE_TIMEOUT_SCALE":           "1.5",
+			"MONGO_SMOKE_MONGO_REPLICA_SET_NAME":  "rs0",
+			"MONGO_SMOKE_GROUPS":                  "destructive=true, auth=false",
+		}
+
+		cfg := expected
+		cfg.MongoConnectTimeoutMS = 3000
+		Expect(cfg.applyEnvOverrides(lookup(env))).To(Succeed())
+		Expect(cfg.MongoHosts).To(Equal([]string{"h3", "h4"}))
+		Expect(cfg.MongoRootPassword).To(Equal("secret"))
+		Expect(cfg.MongoTLS).To(BeTrue())
+		Expect(cfg.MongoSocketTimeoutMS).To(Equal(500))
+		Expect(cfg.TimeoutScale).To(Equal(1.5))
+		Expect(cfg.MongoReplicaSetName).To(Equal("rs0"))
+		Expect(cfg.MongoPort).To(Equal("27017"))
+		Expect(cfg.MongoConnectTimeoutMS).To(Equal(3000))
+		Expect(cfg.Groups).To(Equal(map[string]bool{"destructive": true, "auth": false}))
+	})
+
+	It("should clear fields whose variable is set but empty", func() {
+		cfg := testConfig{MongoHost: "h0", MongoPort: "27017", MongoTLS: true, MongoConnectTimeoutMS: 3000}
+		env := map[string]string{
+			"MONGO_SMOKE_MONGO_HOST":               "",
+			"MONGO_SMOKE_MONGO_HOSTS":              "h1,h2",
+			"MONGO_SMOKE_MONGO_TLS":                "",
+			"MONGO_SMOKE_MONGO_CONNECT_TIMEOUT_MS": "",
+		}
+
+		Expect(cfg.applyEnvOverrides(lookup(env))).To(Succeed())
+		Expect(cfg.MongoHost).To(BeEmpty())
+		Expect(cfg.MongoHosts).To(Equal([]string{"h1", "h2"}))
+		Expect(cfg.MongoTLS).To(BeFalse())
+		Expect(cfg.MongoConnectTimeoutMS).To(BeZero())
+		Expect(cfg.MongoPort).To(Equal("27017"))
+	})
+
+	Context("When VCAP_SERVICES has no MongoDB binding", func() {
+
+		var saved map[string]string
+
+		BeforeEach(func() {
+			saved = map[string]string{}
+			for _, kv := range os.Environ() {
+				parts := strings.SplitN(kv, "=", 2)
+				if strings.HasPrefix(parts[0], envPrefix) || parts[0] == "MONGO_URI" || parts[0] == "VCAP_SERVICES" {
+					saved[parts[0]] = parts[1]
+					os.Unsetenv(parts[0])
+				}
+			}
+			os.Setenv("VCAP_SERVICES", "{}")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("VCAP_SERVICES")
+			os.Unsetenv("MONGO_URI")
+			os.Unsetenv(envPrefix + "MONGO_SERVICE")
+			for key, value := range saved {
+				os.Setenv(key, value)
+			}
+		})
+
+		It("should ignore it when another source is given", func() {
+			os.Setenv("MONGO_URI", "mongodb://root:secret@h1")
+			cfg, err := loadConfig("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.addrs()).To(Equal([]string{"h1:27017"}))
+		})
+
+		It("should require a binding when it is the only source or a service is named", func() {
+			_, err := loadConfig("")
+			Expect(err).To(MatchError(ContainSubstring("no binding for a MongoDB service")))
+
+			os.Setenv("MONGO_URI", "mongodb://root:secret@h1")
+			os.Setenv(envPrefix+"MONGO_SERVICE", "my-mongo")
+			_, err = loadConfig("")
+			Expect(err).To(MatchError(ContainSubstring(`no binding for service "my-mongo"`)))
+		})
+	})
+
+	It("should accept a complete configuration", func() {
+		cfg := testConfig{
+			MongoHosts:        []string{"h1", "h2:27018", "[::1]:27019", "::1"},
+			MongoPort:         "27017",
+			MongoRoot:         "root",
+			MongoRootPassword: "secret",
+		}
+		Expect(cfg.validate()).To(Succeed())
+	})
+
+	It("should build the seed list from the hosts and mongo_port", func() {
+		cfg := testConfig{
+			MongoHosts: []string{"h1", "h2:27018", "[::1]:27019", "::1", "[fe80::1]"},
+			MongoPort:  "27017",
+		}
+		Expect(cfg.addrs()).To(Equal([]string{"h1:27017", "h2:27018", "[::1]:27019", "[::1]:27017", "[fe80::1]:27017"}))
+
+		cfg = testConfig{MongoHost: "h0", MongoPort: "27017"}
+		Expect(cfg.addrs()).To(Equal([]string{"h0:27017"}))
+	})
+
+	It("should report every configuration problem at once", func() {
+		cfg := testConfig{
+			MongoHost:            "h0",
+			MongoHosts:           []string{"h1", "bad host", "h2:99999"},
+			MongoPort:            "",
+			TimeoutScale:         -1,
+			MongoSocketTimeoutMS: -5,
+		}
+		err := cfg.validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.(configError)).To(ConsistOf(
+			"mongo_host and mongo_hosts are mutually exclusive",
+			`invalid host "bad host"`,
+			`invalid port "99999" in host "h2:99999"`,
+			`mongo_port must be a number between 1 and 65535, got ""`,
+			"mongo_root_username is required",
+			"mongo_root_password is required",
+			"timeout_scale must not be negative, got -1",
+			"mongo_socket_timeout_ms must not be negative",
+		))
+	})
+
+	It("should report inconsistent TLS options", func() {
+		cfg := testConfig{MongoHost: "h1", MongoPort: "27017", MongoRoot: "root", MongoRootPassword: "secret"}
+
+		cfg.MongoTLSRequired = true
+		Expect(cfg.validate()).To(MatchError(ContainSubstring("mongo_tls_* options are set but mongo_tls is false")))
+
+		cfg.MongoTLS = true
+		cfg.MongoTLSCertFile = "client.pem"
+		cfg.MongoTLSInsecure = true
+		cfg.MongoTLSServerName = "mongo.internal"
+		err := cfg.validate()
+		Expect(err).To(MatchError(ContainSubstring("mongo_tls_cert_file and mongo_tls_key_file must be given together")))
+		Expect(err).To(MatchError(ContainSubstring("mongo_tls_insecure_skip_verify is mutually exclusive")))
+	})
+
+	It("should report unusable authentication mechanisms", func() {
+		cfg := testConfig{MongoHost: "h1", MongoPort: "27017", MongoRoot: "root", MongoRootPassword: "secret"}
+
+		cfg.MongoAuthMechanism = mechanismSCRAMSHA256
+		Expect(cfg.validate()).To(MatchError(ContainSubstring("SCRAM-SHA-256 is not supported by the mgo driver")))
+
+		cfg.MongoAuthMechanism = "GSSAPI"
+		Expect(cfg.validate()).To(MatchError(ContainSubstring(`"GSSAPI" is not supported by the mgo driver`)))
+
+		cfg.MongoAuthMechanism = mechanismX509
+		err := cfg.validate()
+		Expect(err).To(MatchError(ContainSubstring("mongo_root_password must be empty")))
+		Expect(err).To(MatchError(ContainSubstring("needs mongo_tls and mongo_tls_cert_file")))
+		Expect(cfg.authSource()).To(Equal("$external"))
+	})
+
+	It("should reject malformed environment overrides", func() {
+		cfg := testConfig{}
+		err := cfg.applyEnvOverrides(lookup(map[string]string{"MONGO_SMOKE_MONGO_CONNECT_TIMEOUT_MS": "soon"}))
+		Expect(err).To(MatchError("MONGO_SMOKE_MONGO_CONNECT_TIMEOUT_MS must be an integer"))
+	})
+})
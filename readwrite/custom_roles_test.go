@@ -0,0 +1,159 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type privilege struct {
+	Resource bson.M   `bson:"resource"`
+	Actions  []string `bson:"actions"`
+}
+
+// createRole creates role in db with privileges and the roles it
+// inherits from db.
+func createRole(db *mgo.Database, role string, privileges []privilege, inherited ...string) error {
+	roles := []bson.M{}
+	for _, r := range inherited {
+		roles = append(roles, bson.M{"role": r, "db": db.Name})
+	}
+	return db.Run(bson.D{
+		{Name: "createRole", Value: role},
+		{Name: "privileges", Value: privileges},
+		{Name: "roles", Value: roles},
+	}, nil)
+}
+
+var _ = describeGroup(groupAuth, "MongoDB custom roles", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName = "TestDatabase-" + differentiator
+	var db *mgo.Database
+
+	// The custom role may read and write orders, and inherits read
+	// access to the catalog from the base role.
+	var baseRole = "TestCatalogReader" + differentiator
+	var customRole = "TestOrderClerk" + differentiator
+	var created []string
+	var userCreated bool
+
+	var user = mgo.User{
+		Username: "TestClerk" + differentiator,
+		Password: "TestPassword",
+		Roles:    []mgo.Role{mgo.Role(customRole)},
+	}
+
+	BeforeEach(func() {
+		db = nil
+		created = nil
+		userCreated = false
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		if config.boundCredentials() {
+			Skip("creating roles needs root credentials, mongo_database is set")
+		}
+
+		db = rootSession.DB(databaseName)
+		for _, name := range []string{"Orders", "Catalog", "Ledger"} {
+			Expect(db.C(name).Insert(bson.M{"n": 1}, bson.M{"n": 2})).To(Succeed())
+		}
+
+		Expect(createRole(db, baseRole, []privilege{{
+			Resource: bson.M{"db": databaseName, "collection": "Catalog"},
+			Actions:  []string{"find"},
+		}})).To(Succeed())
+		created = append(created, baseRole)
+
+		Expect(createRole(db, customRole, []privilege{{
+			Resource: bson.M{"db": databaseName, "collection": "Orders"},
+			Actions:  []string{"find", "insert", "update"},
+		}}, baseRole)).To(Succeed())
+		created = append(created, customRole)
+
+		Expect(db.UpsertUser(&user)).To(Succeed())
+		userCreated = true
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		if db != nil {
+			if userCreated {
+				Expect(db.RemoveUser(user.Username)).To(Succeed())
+			}
+			// Drop the inheriting role before the role it inherits from.
+			for i := len(created) - 1; i >= 0; i-- {
+				Expect(db.Run(bson.M{"dropRole": created[i]}, nil)).To(Succeed())
+			}
+			db.DropDatabase()
+		}
+		rootSession.Close()
+	})
+
+	It("should grant exactly the role privileges", func() {
+		session := rootSession.New()
+		defer session.Close()
+		session.LogoutAll()
+		Expect(config.login(session, databaseName, user.Username, user.Password)).To(Succeed())
+		clerkDB := session.DB(databaseName)
+
+		find := func(collection string) func() error {
+			return func() error {
+				var doc bson.M
+				return clerkDB.C(collection).Find(nil).One(&doc)
+			}
+		}
+		insert := func(collection string) func() error {
+			return func() error {
+				return clerkDB.C(collection).Insert(bson.M{"n": 3})
+			}
+		}
+
+		checks := []struct {
+			description string
+			allowed     bool
+			run         func() error
+		}{
+			{"find Orders", true, find("Orders")},
+			{"insert Orders", true, insert("Orders")},
+			{"update Orders", true, func() error {
+				return clerkDB.C("Orders").Update(bson.M{"n": 1}, bson.M{"$set": bson.M{"updated": true}})
+			}},
+			{"remove Orders", false, func() error {
+				return clerkDB.C("Orders").Remove(bson.M{"n": 2})
+			}},
+			{"createIndex Orders", false, func() error {
+				return createIndex(clerkDB.C("Orders"), "n")
+			}},
+			{"drop Orders", false, func() error {
+				return clerkDB.C("Orders").DropCollection()
+			}},
+			{"find Catalog (inherited)", true, find("Catalog")},
+			{"insert Catalog", false, insert("Catalog")},
+			{"find Ledger", false, find("Ledger")},
+			{"insert Ledger", false, insert("Ledger")},
+		}
+
+		var mismatches []string
+		for _, check := range checks {
+			err := check.run()
+			switch {
+			case check.allowed && err != nil:
+				mismatches = append(mismatches, fmt.Sprintf("%s should be allowed: %v", check.description, err))
+			case !check.allowed && err == nil:
+				mismatches = append(mismatches, fmt.Sprintf("%s should be denied", check.description))
+			case !check.allowed && !unauthorized(err):
+				mismatches = append(mismatches, fmt.Sprintf("%s failed with a non-authorization error: %v", check.description, err))
+			}
+		}
+		Expect(mismatches).To(BeEmpty())
+	})
+})
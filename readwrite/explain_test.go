@@ -0,0 +1,136 @@
+package readwrite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type explainStage struct {
+	Stage       string         `bson:"stage"`
+	IndexName   string         `bson:"indexName"`
+	InputStage  *explainStage  `bson:"inputStage"`
+	InputStages []explainStage `bson:"inputStages"`
+}
+
+// stages lists the stage names of the plan tree, root first.
+func (s explainStage) stages() []string {
+	names := []string{s.Stage}
+	if s.InputStage != nil {
+		names = append(names, s.InputStage.stages()...)
+	}
+	for _, input := range s.InputStages {
+		names = append(names, input.stages()...)
+	}
+	return names
+}
+
+// indexes lists the index names the plan tree scans.
+func (s explainStage) indexes() []string {
+	var names []string
+	if s.IndexName != "" {
+		names = append(names, s.IndexName)
+	}
+	if s.InputStage != nil {
+		names = append(names, s.InputStage.indexes()...)
+	}
+	for _, input := range s.InputStages {
+		names = append(names, input.indexes()...)
+	}
+	return names
+}
+
+type explainResult struct {
+	QueryPlanner struct {
+		WinningPlan explainStage `bson:"winningPlan"`
+	} `bson:"queryPlanner"`
+	ExecutionStats struct {
+		NReturned         int `bson:"nReturned"`
+		TotalKeysExamined int `bson:"totalKeysExamined"`
+		TotalDocsExamined int `bson:"totalDocsExamined"`
+	} `bson:"executionStats"`
+}
+
+// explain runs q with explain, skipping the spec on servers older than
+// MongoDB 3.0 whose explain output has no query planner section.
+func explain(q *mgo.Query) explainResult {
+	var result explainResult
+	Expect(q.Explain(&result)).To(Succeed())
+	if result.QueryPlanner.WinningPlan.Stage == "" {
+		Skip("explain output predates MongoDB 3.0")
+	}
+	return result
+}
+
+var _ = describeGroup(groupIndexes, "MongoDB query plans", func() {
+
+	const fixtureSize = 100
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var collectionName = "TestExplain-" + differentiator
+	var col *mgo.Collection
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		col = rootSession.DB(config.workDatabase(differentiator)).C(collectionName)
+
+		docs := make([]interface{}, fixtureSize)
+		for i := range docs {
+			docs[i] = bson.M{"n": i, "category": i % 10}
+		}
+		Expect(col.Insert(docs...)).To(Succeed())
+		Expect(col.EnsureIndexKey("n")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		col.DropCollection()
+		rootSession.Close()
+	})
+
+	It("should use the index for an equality match", func() {
+		result := explain(col.Find(bson.M{"n": 42}))
+
+		plan := result.QueryPlanner.WinningPlan
+		Expect(plan.stages()).To(ContainElement("IXSCAN"))
+		Expect(plan.stages()).NotTo(ContainElement("COLLSCAN"))
+		Expect(plan.indexes()).To(ConsistOf("n_1"))
+		Expect(result.ExecutionStats.NReturned).To(Equal(1))
+		Expect(result.ExecutionStats.TotalKeysExamined).To(Equal(1))
+		Expect(result.ExecutionStats.TotalDocsExamined).To(Equal(1))
+	})
+
+	It("should only examine the keys in range for a range query", func() {
+		result := explain(col.Find(bson.M{"n": bson.M{"$gte": 10, "$lt": 20}}))
+
+		Expect(result.QueryPlanner.WinningPlan.stages()).To(ContainElement("IXSCAN"))
+		Expect(result.ExecutionStats.NReturned).To(Equal(10))
+		Expect(result.ExecutionStats.TotalKeysExamined).To(BeNumerically("<=", 11))
+		Expect(result.ExecutionStats.TotalDocsExamined).To(Equal(10))
+	})
+
+	It("should not fetch documents for a covered query", func() {
+		result := explain(col.Find(bson.M{"n": 42}).Select(bson.M{"_id": 0, "n": 1}))
+
+		Expect(result.QueryPlanner.WinningPlan.stages()).To(ContainElement("IXSCAN"))
+		Expect(result.QueryPlanner.WinningPlan.stages()).NotTo(ContainElement("FETCH"))
+		Expect(result.ExecutionStats.TotalDocsExamined).To(Equal(0))
+	})
+
+	It("should scan the whole collection for an unindexed field", func() {
+		result := explain(col.Find(bson.M{"category": 3}))
+
+		Expect(result.QueryPlanner.WinningPlan.stages()).To(ContainElement("COLLSCAN"))
+		Expect(result.ExecutionStats.NReturned).To(Equal(fixtureSize / 10))
+		Expect(result.ExecutionStats.TotalKeysExamined).To(Equal(0))
+		Expect(result.ExecutionStats.TotalDocsExamined).To(Equal(fixtureSize))
+	})
+})
@@ -0,0 +1,216 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"sync"
+	"time"
+)
+
+// stepDownSeconds is how long a stepped down primary stays ineligible.
+const stepDownSeconds = 60
+
+// stepDown asks the current primary to step down. A graceful step-down
+// waits for an electable secondary to catch up; a forced one does not, so
+// unreplicated writes may be rolled back. It closes client connections
+// while doing so, hence connection errors are expected and only command
+// errors, such as no electable secondary, are returned.
+func stepDown(session *mgo.Session, force bool) error {
+	err := session.DB("admin").Run(bson.D{
+		{Name: "replSetStepDown", Value: stepDownSeconds},
+		{Name: "force", Value: force},
+	}, nil)
+	if _, ok := err.(*mgo.QueryError); ok {
+		return err
+	}
+	return nil
+}
+
+// currentPrimary returns the name of the member in PRIMARY state as seen
+// by any reachable member, or "" during an election.
+func currentPrimary(session *mgo.Session) string {
+	s := session.Copy()
+	defer s.Close()
+	s.SetMode(mgo.PrimaryPreferred, true)
+
+	status, err := replSetGetStatus(s)
+	if err != nil {
+		return ""
+	}
+	if p := status.primary(); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// continuousWriter inserts sequentially numbered documents with the
+// given write concern until stopped, recording which were acknowledged
+// and when.
+type continuousWriter struct {
+	col      *mgo.Collection
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu      sync.Mutex
+	acked   []int
+	lastAck time.Time
+	lastErr error
+}
+
+func startContinuousWriter(session *mgo.Session, col *mgo.Collection, safe *mgo.Safe) *continuousWriter {
+	s := session.Copy()
+	s.SetSafe(safe)
+
+	w := &continuousWriter{
+		col:  col.With(s),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *continuousWriter) run() {
+	defer GinkgoRecover()
+	defer close(w.done)
+	defer w.col.Database.Session.Close()
+
+	for seq := 0; ; seq++ {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		err := w.col.Insert(bson.M{"_id": seq, "at": time.Now()})
+
+		w.mu.Lock()
+		if err == nil {
+			w.acked = append(w.acked, seq)
+			w.lastAck = time.Now()
+		} else {
+			w.lastErr = err
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			w.col.Database.Session.Refresh()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
+
+// lastAcknowledged returns the time of the latest acknowledged write.
+func (w *continuousWriter) lastAcknowledged() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastAck
+}
+
+// lastError returns the error of the latest failed write, if any.
+func (w *continuousWriter) lastError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// Stop waits for the writer to exit and returns the acknowledged ids. It
+// may be called more than once.
+func (w *continuousWriter) Stop() []int {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.acked
+}
+
+var _ = describeGroup(groupDestructive, "MongoDB primary failover", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName = "TestDatabase-" + differentiator
+
+	BeforeEach(func() {
+		if config.MongoReplicaSetName == "" {
+			Skip("mongo_replica_set_name is not set")
+		}
+		if config.boundCredentials() {
+			Skip("replSetStepDown needs root credentials, mongo_database is set")
+		}
+
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		rootSession.Refresh()
+		rootSession.DB(databaseName).DropDatabase()
+		rootSession.Close()
+	})
+
+	It("should elect a new primary and resume writes without losing acknowledged ones", func() {
+		t := config.timeouts()
+		col := rootSession.DB(databaseName).C("TestFailover")
+
+		oldPrimary := currentPrimary(rootSession)
+		Expect(oldPrimary).NotTo(BeEmpty(), "no primary before stepping down")
+
+		writer := startContinuousWriter(rootSession, col, &mgo.Safe{
+			WMode:    "majority",
+			WTimeout: int(t.Socket / time.Millisecond),
+		})
+		defer writer.Stop()
+		Eventually(writer.lastAcknowledged).ShouldNot(BeZero(), "no write acknowledged before stepping down")
+
+		// Poll well past the election threshold so that a slow election
+		// is reported with its actual duration rather than as a timeout.
+		ceiling := config.scaled(stepDownSeconds * time.Second)
+		if ceiling < 2*t.Election {
+			ceiling = 2 * t.Election
+		}
+
+		steppedDownAt := time.Now()
+		Expect(stepDown(rootSession, false)).To(Succeed())
+
+		var newPrimary string
+		Eventually(func() string {
+			newPrimary = currentPrimary(rootSession)
+			if newPrimary == oldPrimary {
+				return ""
+			}
+			return newPrimary
+		}, ceiling, 100*time.Millisecond).ShouldNot(BeEmpty(), "no new primary elected within %s", ceiling)
+		electedAt := time.Now()
+
+		Eventually(func() error {
+			if writer.lastAcknowledged().After(electedAt) {
+				return nil
+			}
+			return fmt.Errorf("last write error: %v", writer.lastError())
+		}, ceiling, 100*time.Millisecond).Should(Succeed(), "writes did not resume within %s", ceiling)
+		resumedAt := writer.lastAcknowledged()
+
+		election, unavailable := electedAt.Sub(steppedDownAt), resumedAt.Sub(steppedDownAt)
+		fmt.Fprintf(GinkgoWriter, "primary %s -> %s: elected after %s, writes resumed after %s, last write error: %v\n",
+			oldPrimary, newPrimary, election, unavailable, writer.lastError())
+		Expect(election).To(BeNumerically("<=", t.Election),
+			"the election took %s, more than the %s threshold", election, t.Election)
+
+		acked := writer.Stop()
+		rootSession.Refresh()
+		found, err := col.Find(bson.M{"_id": bson.M{"$in": acked}}).Count()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(Equal(len(acked)), "acknowledged majority writes were lost")
+	})
+})
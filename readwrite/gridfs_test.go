@@ -0,0 +1,87 @@
+package readwrite_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+)
+
+// defaultGridFSFileSizeKB spans several 255kB GridFS chunks.
+const defaultGridFSFileSizeKB = 1024
+
+func (c testConfig) gridFSFileSize() int64 {
+	if c.GridFSFileSizeKB > 0 {
+		return int64(c.GridFSFileSizeKB) * 1024
+	}
+	return defaultGridFSFileSizeKB * 1024
+}
+
+var _ = describeGroup(groupCRUD, "MongoDB GridFS", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var fileName = "smoke-" + differentiator
+	var gfs *mgo.GridFS
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		gfs = rootSession.DB(config.workDatabase(differentiator)).GridFS("TestGridFS-" + differentiator)
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		gfs.Files.DropCollection()
+		gfs.Chunks.DropCollection()
+		rootSession.Close()
+	})
+
+	It("should store, read back and remove a file intact", func() {
+		size := config.gridFSFileSize()
+
+		file, err := gfs.Create(fileName)
+		Expect(err).NotTo(HaveOccurred())
+
+		uploaded := sha256.New()
+		_, err = io.CopyN(io.MultiWriter(file, uploaded), rand.Reader, size)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		id := file.Id()
+
+		By("reading the file back")
+		file, err = gfs.Open(fileName)
+		Expect(err).NotTo(HaveOccurred())
+		downloaded := sha256.New()
+		n, err := io.Copy(downloaded, file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		Expect(n).To(Equal(size))
+		Expect(downloaded.Sum(nil)).To(Equal(uploaded.Sum(nil)), "SHA-256 mismatch")
+
+		By("checking the files and chunks collections")
+		var meta struct {
+			Length    int64 `bson:"length"`
+			ChunkSize int64 `bson:"chunkSize"`
+		}
+		Expect(gfs.Files.FindId(id).One(&meta)).To(Succeed())
+		Expect(meta.Length).To(Equal(size))
+
+		chunks, err := gfs.Chunks.Find(bson.M{"files_id": id}).Count()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(int64(chunks)).To(Equal((size + meta.ChunkSize - 1) / meta.ChunkSize))
+
+		By("removing the file")
+		Expect(gfs.RemoveId(id)).To(Succeed())
+		Expect(gfs.Files.FindId(id).Count()).To(Equal(0))
+		Expect(gfs.Chunks.Find(bson.M{"files_id": id}).Count()).To(Equal(0))
+	})
+})
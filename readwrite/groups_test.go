@@ -0,0 +1,144 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sort"
+	"strings"
+)
+
+// Spec groups. Each group is a top-level container labelled "[<group>]",
+// so ginkgo -focus/-skip keep working on top of the configuration.
+const (
+	groupCRUD        = "crud"
+	groupIndexes     = "indexes"
+	groupAuth        = "auth"
+	groupReplication = "replication"
+	groupPerformance = "performance"
+	groupDestructive = "destructive"
+	groupAudit       = "audit"
+)
+
+var allGroups = []string{groupCRUD, groupIndexes, groupAuth, groupReplication, groupPerformance, groupDestructive, groupAudit}
+
+const defaultProfile = "full"
+
+// profiles lists the groups each profile enables. Destructive specs are
+// never part of a profile and must be enabled explicitly in groups. The
+// audit profile only runs the security audit.
+var profiles = map[string][]string{
+	"audit": {groupAudit},
+	"quick": {groupCRUD, groupAuth},
+	"full":  {groupCRUD, groupIndexes, groupAuth, groupReplication, groupPerformance},
+}
+
+func (c testConfig) profile() string {
+	if c.Profile == "" {
+		return defaultProfile
+	}
+	return c.Profile
+}
+
+// groupEnabled reports whether the specs of group run, and if not why.
+// An entry in groups overrides the profile.
+func (c testConfig) groupEnabled(group string) (bool, string) {
+	if enabled, ok := c.Groups[group]; ok {
+		if enabled {
+			return true, ""
+		}
+		return false, fmt.Sprintf("group %s is disabled in groups", group)
+	}
+
+	for _, g := range profiles[c.profile()] {
+		if g == group {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("group %s is not part of the %s profile", group, c.profile())
+}
+
+// enabledGroups lists the groups that run, in declaration order.
+func (c testConfig) enabledGroups() []string {
+	var enabled []string
+	for _, g := range allGroups {
+		if ok, _ := c.groupEnabled(g); ok {
+			enabled = append(enabled, g)
+		}
+	}
+	return enabled
+}
+
+// validateGroups appends the profile and groups problems to problems.
+func (c testConfig) validateGroups(addf func(format string, args ...interface{})) {
+	if _, ok := profiles[c.profile()]; !ok {
+		var names []string
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		addf("unknown profile %q (known: %s)", c.Profile, strings.Join(names, ", "))
+	}
+
+	for group := range c.Groups {
+		known := false
+		for _, g := range allGroups {
+			known = known || g == group
+		}
+		if !known {
+			addf("unknown group %q in groups (known: %s)", group, strings.Join(allGroups, ", "))
+		}
+	}
+}
+
+// describeGroup declares a top-level container for the specs of group.
+// They are reported as skipped, with the reason, when the group is
+// disabled; teardown nodes of body must tolerate their setup not having
+// run.
+func describeGroup(group, text string, body func()) bool {
+	return Describe("["+group+"] "+text, func() {
+		BeforeEach(func() {
+			if enabled, reason := config.groupEnabled(group); !enabled {
+				Skip(reason)
+			}
+		})
+
+		body()
+	})
+}
+
+var _ = Describe("Spec groups", func() {
+
+	It("should enable the groups of the default profile", func() {
+		Expect(testConfig{}.enabledGroups()).To(Equal(profiles[defaultProfile]))
+	})
+
+	It("should let groups override the profile", func() {
+		cfg := testConfig{
+			Profile: "quick",
+			Groups:  map[string]bool{groupAuth: false, groupDestructive: true},
+		}
+		Expect(cfg.enabledGroups()).To(Equal([]string{groupCRUD, groupDestructive}))
+
+		enabled, reason := cfg.groupEnabled(groupAuth)
+		Expect(enabled).To(BeFalse())
+		Expect(reason).To(Equal("group auth is disabled in groups"))
+
+		enabled, reason = cfg.groupEnabled(groupReplication)
+		Expect(enabled).To(BeFalse())
+		Expect(reason).To(Equal("group replication is not part of the quick profile"))
+	})
+
+	It("should report unknown profiles and groups", func() {
+		var problems []string
+		addf := func(format string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+
+		testConfig{Profile: "nightly", Groups: map[string]bool{"crdu": true}}.validateGroups(addf)
+		Expect(problems).To(ConsistOf(
+			`unknown profile "nightly" (known: audit, full, quick)`,
+			`unknown group "crdu" in groups (known: crud, indexes, auth, replication, performance, destructive, audit)`,
+		))
+	})
+})
@@ -0,0 +1,150 @@
+package readwrite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+// indexNames lists the index names of col.
+func indexNames(col *mgo.Collection) []string {
+	indexes, err := col.Indexes()
+	Expect(err).NotTo(HaveOccurred())
+
+	var names []string
+	for _, index := range indexes {
+		names = append(names, index.Name)
+	}
+	return names
+}
+
+var _ = describeGroup(groupIndexes, "MongoDB indexes", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName string
+	var collectionName = "TestIndexes-" + differentiator
+	var col *mgo.Collection
+	var created []string
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		databaseName = config.workDatabase(differentiator)
+		col = rootSession.DB(databaseName).C(collectionName)
+		created = nil
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		defer rootSession.Close()
+		defer col.DropCollection()
+
+		for _, name := range created {
+			Expect(col.DropIndexName(name)).To(Succeed())
+		}
+		Expect(indexNames(col)).To(Equal([]string{"_id_"}))
+	})
+
+	// ensureIndex creates index and returns its name, checking the server
+	// lists it. The index is dropped again after the spec.
+	ensureIndex := func(index mgo.Index) string {
+		Expect(col.EnsureIndex(index)).To(Succeed())
+
+		indexes, err := col.Indexes()
+		Expect(err).NotTo(HaveOccurred())
+		for _, i := range indexes {
+			if len(i.Key) == len(index.Key) && i.Key[0] == index.Key[0] {
+				created = append(created, i.Name)
+				return i.Name
+			}
+		}
+		Fail("index on " + index.Key[0] + " is not listed")
+		return ""
+	}
+
+	It("should reject duplicate keys on a unique index", func() {
+		ensureIndex(mgo.Index{Key: []string{"email"}, Unique: true})
+
+		Expect(col.Insert(bson.M{"email": "pierre@example.com"})).To(Succeed())
+		err := col.Insert(bson.M{"email": "pierre@example.com"})
+		Expect(mgo.IsDup(err)).To(BeTrue(), "expected a duplicate key error, got %v", err)
+	})
+
+	It("should create a compound index", func() {
+		name := ensureIndex(mgo.Index{Key: []string{"lastName", "-firstName"}})
+		Expect(name).To(Equal("lastName_1_firstName_-1"))
+
+		indexes, err := col.Indexes()
+		Expect(err).NotTo(HaveOccurred())
+		for _, index := range indexes {
+			if index.Name == name {
+				Expect(index.Key).To(Equal([]string{"lastName", "-firstName"}))
+			}
+		}
+	})
+
+	It("should only index documents having the field on a sparse index", func() {
+		ensureIndex(mgo.Index{Key: []string{"nickname"}, Unique: true, Sparse: true})
+
+		Expect(col.Insert(bson.M{"name": "a"}, bson.M{"name": "b"})).To(Succeed())
+		Expect(col.Insert(bson.M{"nickname": "pierrot"})).To(Succeed())
+		Expect(mgo.IsDup(col.Insert(bson.M{"nickname": "pierrot"}))).To(BeTrue())
+	})
+
+	It("should only index documents matching the filter of a partial index", func() {
+		err := col.Database.Run(bson.D{
+			{Name: "createIndexes", Value: col.Name},
+			{Name: "indexes", Value: []bson.M{{
+				"key":                     bson.M{"email": 1},
+				"name":                    "email_partial",
+				"unique":                  true,
+				"partialFilterExpression": bson.M{"active": true},
+			}}},
+		}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		created = append(created, "email_partial")
+		Expect(indexNames(col)).To(ContainElement("email_partial"))
+
+		Expect(col.Insert(bson.M{"email": "a@example.com", "active": false})).To(Succeed())
+		Expect(col.Insert(bson.M{"email": "a@example.com", "active": false})).To(Succeed())
+		Expect(col.Insert(bson.M{"email": "a@example.com", "active": true})).To(Succeed())
+		Expect(mgo.IsDup(col.Insert(bson.M{"email": "a@example.com", "active": true}))).To(BeTrue())
+	})
+
+	It("should find documents through a text index", func() {
+		ensureIndex(mgo.Index{Key: []string{"$text:body"}})
+
+		Expect(col.Insert(
+			bson.M{"body": "MongoDB smoke tests for Cloud Foundry"},
+			bson.M{"body": "Replica sets elect a primary"},
+			bson.M{"body": "Cloud Foundry service brokers"},
+		)).To(Succeed())
+
+		var found []bson.M
+		err := col.Find(bson.M{"$text": bson.M{"$search": "foundry"}}).All(&found)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(HaveLen(2))
+	})
+
+	It("should reap expired documents through a TTL index", func() {
+		ensureIndex(mgo.Index{Key: []string{"createdAt"}, ExpireAfter: time.Second})
+
+		Expect(col.Insert(
+			bson.M{"createdAt": time.Now().Add(-time.Hour)},
+			bson.M{"createdAt": time.Now().Add(time.Hour)},
+		)).To(Succeed())
+
+		deadline := config.scaled(baseTTLReaping)
+		Eventually(func() (int, error) {
+			return col.Count()
+		}, deadline, time.Second).Should(Equal(1), "expired document not reaped within %s", deadline)
+	})
+})
@@ -0,0 +1,300 @@
+package readwrite_test
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	mongoURIScheme      = "mongodb://"
+	mongoURIDefaultPort = "27017"
+)
+
+// uriWarnings receives the options of a connection string that have no
+// testConfig counterpart, such as w or appName, which are ignored.
+var uriWarnings io.Writer = os.Stderr
+
+// uriError reports a malformed connection string. The URI itself is never
+// echoed back since it usually carries a password.
+func uriError(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid mongo_uri: "+format, args...)
+}
+
+// mongoURIParts are the raw components of a connection string.
+type mongoURIParts struct {
+	userinfo    string
+	hasUserinfo bool
+	hosts       string
+	database    string
+	query       string
+}
+
+// splitMongoURI cuts a mongodb:// connection string into its components
+// and unescapes the database name.
+func splitMongoURI(uri string) (parts mongoURIParts, err error) {
+	if !strings.HasPrefix(uri, mongoURIScheme) {
+		return parts, uriError("must start with %q", mongoURIScheme)
+	}
+	rest := strings.TrimPrefix(uri, mongoURIScheme)
+
+	// The userinfo ends at the last "@" of the authority, which stops at
+	// the first "/" or "?": options such as appName may contain "@" too.
+	authority := rest
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority = rest[:i]
+	}
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		parts.userinfo, rest, parts.hasUserinfo = rest[:i], rest[i+1:], true
+	}
+
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, parts.query = rest[:i], rest[i+1:]
+	}
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest, parts.database = rest[:i], rest[i+1:]
+	}
+	parts.hosts = rest
+
+	if parts.database != "" {
+		if parts.database, err = url.PathUnescape(parts.database); err != nil {
+			return parts, uriError("bad database name escaping")
+		}
+	}
+	return parts, nil
+}
+
+// mongoURIDatabase returns the database path of a connection string,
+// which is not necessarily its auth source.
+func mongoURIDatabase(uri string) (string, error) {
+	parts, err := splitMongoURI(uri)
+	return parts.database, err
+}
+
+// applyMongoURI parses a mongodb:// connection string and overrides the
+// matching discrete fields of c. Hosts are always taken from the URI;
+// credentials, auth source, replica set, TLS and timeouts only when the
+// URI specifies them. Other options are ignored with a warning.
+func (c *testConfig) applyMongoURI(uri string) error {
+	parts, err := splitMongoURI(uri)
+	if err != nil {
+		return err
+	}
+	hasUserinfo, database := parts.hasUserinfo, parts.database
+
+	hosts, err := parseURIHosts(parts.hosts)
+	if err != nil {
+		return err
+	}
+
+	var username, password string
+	if hasUserinfo {
+		if username, password, err = parseURIUserinfo(parts.userinfo); err != nil {
+			return err
+		}
+	}
+
+	options, err := url.ParseQuery(parts.query)
+	if err != nil {
+		return uriError("bad options: %s", err)
+	}
+
+	c.MongoHosts = hosts
+	c.MongoHost = ""
+	if hasUserinfo {
+		c.MongoRoot = username
+		c.MongoRootPassword = password
+	}
+	if database != "" {
+		c.MongoAuthSource = database
+	}
+
+	for name, values := range options {
+		if len(values) != 1 {
+			return uriError("option %q given more than once", name)
+		}
+		value := values[0]
+
+		switch name {
+		case "replicaSet":
+			c.MongoReplicaSetName = value
+		case "authSource":
+			c.MongoAuthSource = value
+		case "authMechanism":
+			c.MongoAuthMechanism = value
+		case "ssl", "tls":
+			if c.MongoTLS, err = strconv.ParseBool(value); err != nil {
+				return uriError("option %s must be true or false, got %q", name, value)
+			}
+		case "connectTimeoutMS":
+			if c.MongoConnectTimeoutMS, err = parseURIMillis(name, value); err != nil {
+				return err
+			}
+		case "socketTimeoutMS":
+			if c.MongoSocketTimeoutMS, err = parseURIMillis(name, value); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(uriWarnings, "mongo_uri: ignoring option %s, the suite does not use it\n", name)
+		}
+	}
+
+	return nil
+}
+
+func parseURIHosts(s string) ([]string, error) {
+	if s == "" {
+		return nil, uriError("no hosts given")
+	}
+
+	var hosts []string
+	for _, h := range strings.Split(s, ",") {
+		if h == "" {
+			return nil, uriError("empty host in host list")
+		}
+
+		host, port, err := net.SplitHostPort(h)
+		if err != nil {
+			if strings.Contains(strings.Trim(h, "[]"), ":") && !strings.HasPrefix(h, "[") {
+				return nil, uriError("IPv6 host %q must be enclosed in brackets", h)
+			}
+			host, port = strings.Trim(h, "[]"), mongoURIDefaultPort
+		}
+		if host == "" {
+			return nil, uriError("empty host name in %q", h)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, uriError("bad port %q for host %q", port, host)
+		}
+
+		hosts = append(hosts, net.JoinHostPort(host, port))
+	}
+	return hosts, nil
+}
+
+func parseURIUserinfo(s string) (username, password string, err error) {
+	username = s
+	if i := strings.Index(s, ":"); i >= 0 {
+		username, password = s[:i], s[i+1:]
+	}
+
+	if username, err = url.PathUnescape(username); err != nil {
+		return "", "", uriError("bad username escaping")
+	}
+	if password, err = url.PathUnescape(password); err != nil {
+		return "", "", uriError("bad password escaping")
+	}
+	if username == "" {
+		return "", "", uriError("empty username")
+	}
+	return username, password, nil
+}
+
+func parseURIMillis(name, value string) (int, error) {
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 0 {
+		return 0, uriError("option %s must be a non-negative number of milliseconds, got %q", name, value)
+	}
+	return ms, nil
+}
+
+var _ = Describe("MongoDB connection string", func() {
+
+	It("should override the discrete fields", func() {
+		cfg := testConfig{
+			MongoHost:         "old-host",
+			MongoPort:         "1234",
+			MongoRoot:         "old-user",
+			MongoRootPassword: "old-password",
+		}
+
+		err := cfg.applyMongoURI("mongodb://user:p%40ss@h1,h2:27018,[::1]:27019/db" +
+			"?replicaSet=rs0&authSource=admin&authMechanism=SCRAM-SHA-1&ssl=true&connectTimeoutMS=5000&socketTimeoutMS=7000")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.addrs()).To(Equal([]string{"h1:27017", "h2:27018", "[::1]:27019"}))
+		Expect(cfg.MongoRoot).To(Equal("user"))
+		Expect(cfg.MongoRootPassword).To(Equal("p@ss"))
+		Expect(cfg.MongoAuthSource).To(Equal("admin"))
+		Expect(cfg.MongoAuthMechanism).To(Equal("SCRAM-SHA-1"))
+		Expect(cfg.MongoReplicaSetName).To(Equal("rs0"))
+		Expect(cfg.MongoTLS).To(BeTrue())
+		Expect(cfg.MongoConnectTimeoutMS).To(Equal(5000))
+		Expect(cfg.MongoSocketTimeoutMS).To(Equal(7000))
+	})
+
+	It("should ignore options the suite does not use", func() {
+		var warnings bytes.Buffer
+		uriWarnings = &warnings
+		defer func() { uriWarnings = os.Stderr }()
+
+		cfg := testConfig{}
+		Expect(cfg.applyMongoURI("mongodb://user:secret@h1/?w=majority&replicaSet=rs0&appName=broker")).To(Succeed())
+		Expect(cfg.MongoReplicaSetName).To(Equal("rs0"))
+		Expect(warnings.String()).To(ContainSubstring("ignoring option w,"))
+		Expect(warnings.String()).To(ContainSubstring("ignoring option appName,"))
+		Expect(warnings.String()).NotTo(ContainSubstring("majority"))
+	})
+
+	DescribeTable("should not look for credentials past the host list",
+		func(uri string, hosts []string, username, password, replicaSet string) {
+			var warnings bytes.Buffer
+			uriWarnings = &warnings
+			defer func() { uriWarnings = os.Stderr }()
+
+			cfg := testConfig{MongoRoot: "root"}
+			Expect(cfg.applyMongoURI(uri)).To(Succeed())
+			Expect(cfg.addrs()).To(Equal(hosts))
+			Expect(cfg.MongoRoot).To(Equal(username))
+			Expect(cfg.MongoRootPassword).To(Equal(password))
+			Expect(cfg.MongoReplicaSetName).To(Equal(replicaSet))
+		},
+		Entry("@ in an option after a database", "mongodb://u:p@h1,h2/db?replicaSet=rs0&appName=ops@team",
+			[]string{"h1:27017", "h2:27017"}, "u", "p", "rs0"),
+		Entry("@ in an option without a database", "mongodb://u:p@h1?appName=ops@team",
+			[]string{"h1:27017"}, "u", "p", ""),
+		Entry("@ in an option without credentials", "mongodb://h1/?appName=ops@team",
+			[]string{"h1:27017"}, "root", "", ""),
+	)
+
+	It("should default the auth source to the database path", func() {
+		cfg := testConfig{}
+		Expect(cfg.applyMongoURI("mongodb://user:pass@h1/tenant")).To(Succeed())
+		Expect(cfg.MongoAuthSource).To(Equal("tenant"))
+	})
+
+	It("should keep discrete credentials when the URI has none", func() {
+		cfg := testConfig{MongoRoot: "root", MongoRootPassword: "secret"}
+		Expect(cfg.applyMongoURI("mongodb://h1")).To(Succeed())
+		Expect(cfg.MongoRoot).To(Equal("root"))
+		Expect(cfg.MongoRootPassword).To(Equal("secret"))
+	})
+
+	DescribeTable("should reject malformed URIs",
+		func(uri, reason string) {
+			cfg := testConfig{}
+			err := cfg.applyMongoURI(uri)
+			Expect(err).To(MatchError(ContainSubstring(reason)))
+			Expect(err.Error()).NotTo(ContainSubstring("secret"))
+		},
+		Entry("wrong scheme", "http://user:secret@h1", "must start with"),
+		Entry("no hosts", "mongodb://user:secret@/db", "no hosts"),
+		Entry("empty host", "mongodb://user:secret@h1,,h2", "empty host"),
+		Entry("bad port", "mongodb://user:secret@h1:port", "bad port"),
+		Entry("unbracketed IPv6", "mongodb://user:secret@::1", "brackets"),
+		Entry("empty username", "mongodb://:secret@h1", "empty username"),
+		Entry("bad escaping", "mongodb://user:secret%zz@h1", "bad password escaping"),
+		Entry("bad bool", "mongodb://user:secret@h1/?ssl=maybe", "must be true or false"),
+		Entry("bad timeout", "mongodb://user:secret@h1/?socketTimeoutMS=-1", "non-negative"),
+		Entry("bad option syntax", "mongodb://user:secret@h1/?w=%zz", "bad options"),
+	)
+})
@@ -0,0 +1,266 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server error codes returned for read concerns a deployment cannot honour.
+const (
+	codeFailedToParse                 = 9
+	codeCommandNotFound               = 59
+	codeInvalidOptions                = 72
+	codeNotMaster                     = 10107
+	codeReadConcernMajorityNotEnabled = 148
+)
+
+type findResult struct {
+	Cursor struct {
+		FirstBatch []bson.M `bson:"firstBatch"`
+	} `bson:"cursor"`
+}
+
+// findWithReadConcern runs a find command with the given read concern
+// level and returns its first batch.
+func findWithReadConcern(db *mgo.Database, collection string, filter bson.M, level string) ([]bson.M, error) {
+	var result findResult
+	err := db.Run(bson.D{
+		{Name: "find", Value: collection},
+		{Name: "filter", Value: filter},
+		{Name: "batchSize", Value: 10000},
+		{Name: "readConcern", Value: bson.M{"level": level}},
+		{Name: "maxTimeMS", Value: int(config.timeouts().Socket / time.Millisecond)},
+	}, &result)
+	return result.Cursor.FirstBatch, err
+}
+
+// unsupportedReadConcern returns why err means the deployment does not
+// support the read concern level, or "" for any other error.
+func unsupportedReadConcern(level string, err error) string {
+	qerr, ok := err.(*mgo.QueryError)
+	if !ok {
+		return ""
+	}
+
+	var category string
+	switch {
+	case qerr.Code == codeCommandNotFound || strings.HasPrefix(qerr.Message, "no such cmd"):
+		category = "server version: the find command needs MongoDB 3.2"
+	case qerr.Code == codeReadConcernMajorityNotEnabled:
+		category = "server configuration: enableMajorityReadConcern is off"
+	case qerr.Code == codeNotMaster:
+		category = "topology: only a primary serves this level"
+	case (qerr.Code == codeInvalidOptions || qerr.Code == codeFailedToParse) && strings.Contains(qerr.Message, level):
+		category = "server version or topology"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("read concern %s unsupported (%s): %s", level, category, qerr.Message)
+}
+
+// toInt64 converts a decoded BSON integer, stored as int32 or int64.
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return -1
+}
+
+var _ = describeGroup(groupReplication, "MongoDB read concerns", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName string
+	var collectionName = "TestReadConcern-" + differentiator
+	var id bson.ObjectId
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		databaseName = config.workDatabase(differentiator)
+
+		session := rootSession.Copy()
+		defer session.Close()
+		session.SetSafe(&mgo.Safe{WMode: "majority"})
+
+		id = bson.NewObjectId()
+		err = session.DB(databaseName).C(collectionName).Insert(bson.M{"_id": id, "Name": "some-item"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		rootSession.DB(databaseName).C(collectionName).DropCollection()
+		rootSession.Close()
+	})
+
+	DescribeTable("should read a majority-acknowledged document",
+		func(level string) {
+			docs, err := findWithReadConcern(rootSession.DB(databaseName), collectionName, bson.M{"_id": id}, level)
+			if reason := unsupportedReadConcern(level, err); reason != "" {
+				Skip(reason)
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(docs).To(HaveLen(1))
+			Expect(docs[0]["Name"]).To(Equal("some-item"))
+		},
+		Entry("with read concern local", "local"),
+		Entry("with read concern majority", "majority"),
+		Entry("with read concern linearizable", "linearizable"),
+	)
+})
+
+var _ = describeGroup(groupDestructive, "MongoDB majority reads across a forced step-down", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName = "TestDatabase-" + differentiator
+
+	BeforeEach(func() {
+		if config.MongoReplicaSetName == "" {
+			Skip("mongo_replica_set_name is not set")
+		}
+		if config.boundCredentials() {
+			Skip("replSetStepDown needs root credentials, mongo_database is set")
+		}
+
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		rootSession.Refresh()
+		rootSession.DB(databaseName).DropDatabase()
+		rootSession.Close()
+	})
+
+	It("should never return a document that is later rolled back", func() {
+		t := config.timeouts()
+		col := rootSession.DB(databaseName).C("TestRollback")
+
+		_, err := findWithReadConcern(col.Database, col.Name, bson.M{}, "majority")
+		if reason := unsupportedReadConcern("majority", err); reason != "" {
+			Skip(reason)
+		}
+		Expect(err).NotTo(HaveOccurred())
+
+		oldPrimary := currentPrimary(rootSession)
+		Expect(oldPrimary).NotTo(BeEmpty(), "no primary before stepping down")
+
+		// w:1 writes in flight during the step-down are the ones a
+		// rollback may discard.
+		writer := startContinuousWriter(rootSession, col, &mgo.Safe{W: 1})
+		defer writer.Stop()
+
+		var mu sync.Mutex
+		observed := map[int64]bool{}
+		stopReader := make(chan struct{})
+		readerDone := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(readerDone)
+
+			reader := rootSession.Copy()
+			defer reader.Close()
+			reader.SetMode(mgo.PrimaryPreferred, true)
+
+			last := int64(-1)
+			for {
+				select {
+				case <-stopReader:
+					return
+				default:
+				}
+
+				filter := bson.M{"_id": bson.M{"$gt": last}}
+				docs, err := findWithReadConcern(reader.DB(databaseName), col.Name, filter, "majority")
+				if err != nil {
+					reader.Refresh()
+					time.Sleep(50 * time.Millisecond)
+					continue
+				}
+				mu.Lock()
+				for _, doc := range docs {
+					id := toInt64(doc["_id"])
+					observed[id] = true
+					if id > last {
+						last = id
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+
+		Eventually(writer.lastAcknowledged).ShouldNot(BeZero(), "no write acknowledged before stepping down")
+		Expect(stepDown(rootSession, true)).To(Succeed())
+
+		Eventually(func() bool {
+			p := currentPrimary(rootSession)
+			return p != "" && p != oldPrimary
+		}, t.Election, 100*time.Millisecond).Should(BeTrue(), "no new primary elected within %s", t.Election)
+
+		acked := writer.Stop()
+		close(stopReader)
+		<-readerDone
+
+		// Let the old primary roll back and rejoin as a secondary.
+		Eventually(func() string {
+			status, err := replSetGetStatus(rootSession)
+			if err != nil {
+				rootSession.Refresh()
+				return err.Error()
+			}
+			for _, m := range status.Members {
+				if m.Name == oldPrimary {
+					return m.StateStr
+				}
+			}
+			return "missing"
+		}, t.Election, time.Second).Should(Equal("SECONDARY"))
+
+		rootSession.Refresh()
+		var kept []struct {
+			Id int `bson:"_id"`
+		}
+		err = col.Find(bson.M{"_id": bson.M{"$in": acked}}).Select(bson.M{"_id": 1}).All(&kept)
+		Expect(err).NotTo(HaveOccurred())
+		rolledBack := len(acked) - len(kept)
+		fmt.Fprintf(GinkgoWriter, "%d of %d acknowledged w:1 writes rolled back\n", rolledBack, len(acked))
+		if rolledBack == 0 {
+			Skip("no rollback occurred")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		var lost []int64
+		for id := range observed {
+			n, err := col.FindId(id).Count()
+			Expect(err).NotTo(HaveOccurred())
+			if n == 0 {
+				lost = append(lost, id)
+			}
+		}
+		fmt.Fprintf(GinkgoWriter, "%d documents seen by majority reads\n", len(observed))
+		Expect(lost).To(BeEmpty(), "documents returned by majority reads were rolled back")
+	})
+})
@@ -1,45 +1,35 @@
 package readwrite_test
 
 import (
-	"encoding/json"
 	"fmt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"os"
+	"strings"
 	"testing"
 )
 
-type testConfig struct {
-	TimeoutScale        float64 `json:"timeout_scale"`
-	MongoHost           string  `json:"mongo_host"`
-	MongoPort           string  `json:"mongo_port"`
-	MongoRoot           string  `json:"mongo_root_username"`
-	MongoRootPassword   string  `json:"mongo_root_password"`
-	MongoReplicaSetName string  `json:"mongo_replica_set_name"`
-}
+// exitCodeInvalidConfig is the exit status of the test binary when the
+// configuration could not be loaded, telling it apart from failing specs.
+const exitCodeInvalidConfig = 3
 
-func loadConfig(path string) (cfg testConfig) {
-	configFile, err := os.Open(path)
-	if err != nil {
-		fatal(err)
-	}
+var (
+	config    testConfig
+	configErr error
+)
 
-	decoder := json.NewDecoder(configFile)
-	if err = decoder.Decode(&cfg); err != nil {
-		fatal(err)
+var _ = BeforeSuite(func() {
+	if configErr != nil {
+		Fail(configErr.Error())
 	}
 
-	return
-}
+	t := config.timeouts()
+	SetDefaultEventuallyTimeout(t.Eventually)
+	SetDefaultConsistentlyDuration(t.Consistently)
 
-var (
-	config = loadConfig(os.Getenv("CONFIG_PATH"))
-)
-
-func fatal(err error) {
-	fmt.Printf("ERROR: %s\n", err.Error())
-	os.Exit(1)
-}
+	fmt.Printf("Effective timeouts (timeout_scale %g): %s\n", config.scale(), t)
+	fmt.Printf("Enabled groups (profile %s): %s\n", config.profile(), strings.Join(config.enabledGroups(), ", "))
+})
 
 func TestReadwrite(t *testing.T) {
 
@@ -47,3 +37,13 @@ func TestReadwrite(t *testing.T) {
 
 	RunSpecs(t, "MongoDB Acceptance Tests")
 }
+
+func TestMain(m *testing.M) {
+	config, configErr = loadConfig(os.Getenv("CONFIG_PATH"))
+
+	code := m.Run()
+	if configErr != nil {
+		code = exitCodeInvalidConfig
+	}
+	os.Exit(code)
+}
@@ -8,14 +8,7 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
-var _ = Describe("MongoDB CRUD tests", func() {
-
-	var connInfo = &mgo.DialInfo{
-		Addrs:          []string{config.MongoHost + ":" + config.MongoPort},
-		ReplicaSetName: config.MongoReplicaSetName,
-		Username:       config.MongoRoot,
-		Password:       config.MongoRootPassword,
-	}
+var _ = describeGroup(groupCRUD, "MongoDB CRUD tests", func() {
 
 	var rootSession *mgo.Session
 	var err error
@@ -23,45 +16,24 @@ var _ = Describe("MongoDB CRUD tests", func() {
 	var differentiator = uuid.NewV4().String()
 
 	BeforeEach(func() {
-		rootSession, err = mgo.DialWithInfo(connInfo)
+		rootSession, err = config.dial()
 		Expect(err).NotTo(HaveOccurred())
 	})
 
 	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
 		rootSession.LogoutAll()
 		rootSession.Close()
 	})
 
-	Context("When an admin user is created", func() {
-
-		var databaseName = "TestDatabase-" + differentiator
-		var db *mgo.Database
-
-		var admin = mgo.User{
-			Username: "TestUsername" + differentiator,
-			Password: "TestPassword",
-			Roles:    []mgo.Role{mgo.RoleDBAdmin},
-		}
+	// itPerformsCRUD declares the document round-trip specs against the
+	// database returned by database, which logs in as needed.
+	itPerformsCRUD := func(description string, database func() *mgo.Database, collectionName string) {
 
-		BeforeEach(func() {
-			db = rootSession.DB(databaseName)
-			err := db.UpsertUser(&admin)
-			Expect(err).NotTo(HaveOccurred())
-		})
+		Context(description, func() {
 
-		AfterEach(func() {
-			err := db.RemoveUser(admin.Username)
-			Expect(err).NotTo(HaveOccurred())
-		})
-
-		It("should login successfully as that user", func() {
-			err := db.Login(admin.Username, admin.Password)
-			Expect(err).NotTo(HaveOccurred())
-		})
-
-		Context("When connected to a database as an admin user", func() {
-
-			var collectionName = "TestCollection"
 			var col *mgo.Collection
 
 			type Item struct {
@@ -73,11 +45,8 @@ var _ = Describe("MongoDB CRUD tests", func() {
 			var item = Item{"", itemName}
 
 			BeforeEach(func() {
-				err := db.Login(admin.Username, admin.Password)
-				Expect(err).NotTo(HaveOccurred())
-
-				col = db.C(collectionName)
-				err = col.Insert(item)
+				col = database().C(collectionName)
+				err := col.Insert(item)
 				Expect(err).NotTo(HaveOccurred())
 			})
 
@@ -92,7 +61,8 @@ var _ = Describe("MongoDB CRUD tests", func() {
 
 			It("should update an existing document", func() {
 				newItemName := "Pierre"
-				col.Update(bson.M{"Name": itemName}, bson.M{"$set": bson.M{"Name": newItemName}})
+				err := col.Update(bson.M{"Name": itemName}, bson.M{"$set": bson.M{"Name": newItemName}})
+				Expect(err).NotTo(HaveOccurred())
 
 				search := col.Find(bson.M{"Name": newItemName})
 				Expect(search.Count()).To(Equal(1))
@@ -106,5 +76,60 @@ var _ = Describe("MongoDB CRUD tests", func() {
 				Expect(items.Count()).To(Equal(0))
 			})
 		})
+	}
+
+	Context("When an admin user is created", func() {
+
+		var databaseName = "TestDatabase-" + differentiator
+		var db *mgo.Database
+
+		var admin = mgo.User{
+			Username: "TestUsername" + differentiator,
+			Password: "TestPassword",
+			Roles:    []mgo.Role{mgo.RoleDBAdmin},
+		}
+
+		BeforeEach(func() {
+			db = nil
+			if config.boundCredentials() {
+				Skip("creating users needs root credentials, mongo_database is set")
+			}
+
+			db = rootSession.DB(databaseName)
+			err := db.UpsertUser(&admin)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if db == nil {
+				return
+			}
+			err := db.RemoveUser(admin.Username)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should login successfully as that user", func() {
+			err := config.login(rootSession, databaseName, admin.Username, admin.Password)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		itPerformsCRUD("When connected to a database as an admin user", func() *mgo.Database {
+			err := config.login(rootSession, databaseName, admin.Username, admin.Password)
+			Expect(err).NotTo(HaveOccurred())
+			return db
+		}, "TestCollection")
+	})
+
+	Context("When bound service credentials are used", func() {
+
+		BeforeEach(func() {
+			if !config.boundCredentials() {
+				Skip("mongo_database is not set, the suite provisions its own database")
+			}
+		})
+
+		itPerformsCRUD("When connected to the bound database", func() *mgo.Database {
+			return rootSession.DB(config.MongoDatabase)
+		}, "TestCollection-"+differentiator)
 	})
 })
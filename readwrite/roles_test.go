@@ -0,0 +1,175 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+)
+
+// codeUnauthorized is returned when the authenticated users lack a privilege.
+const codeUnauthorized = 13
+
+// unauthorized reports whether err is a server authorization failure.
+func unauthorized(err error) bool {
+	switch e := err.(type) {
+	case *mgo.QueryError:
+		return e.Code == codeUnauthorized || strings.HasPrefix(e.Message, "not authorized")
+	case *mgo.LastError:
+		return e.Code == codeUnauthorized || strings.HasPrefix(e.Err, "not authorized")
+	}
+	return false
+}
+
+// createIndex creates an ascending index on key with the createIndexes
+// command. Unlike EnsureIndex it is not cached per cluster, so it always
+// reaches the server with the session's credentials.
+func createIndex(col *mgo.Collection, key string) error {
+	return col.Database.Run(bson.D{
+		{Name: "createIndexes", Value: col.Name},
+		{Name: "indexes", Value: []bson.M{{"key": bson.M{key: 1}, "name": key + "_1"}}},
+	}, nil)
+}
+
+// Operations of the role permission matrix. dropDatabase runs last since
+// it removes what the others work on.
+const (
+	opFind         = "find"
+	opInsert       = "insert"
+	opCreateIndex  = "createIndex"
+	opCreateUser   = "createUser"
+	opServerStatus = "serverStatus"
+	opDropDatabase = "dropDatabase"
+)
+
+var matrixOperations = []string{opFind, opInsert, opCreateIndex, opCreateUser, opServerStatus, opDropDatabase}
+
+type roleCase struct {
+	role mgo.Role
+	// cluster roles are only granted on the admin database.
+	cluster bool
+	allowed []string
+}
+
+var _ = describeGroup(groupAuth, "MongoDB built-in roles", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName = "TestDatabase-" + differentiator
+	var collectionName = "TestCollection"
+	var createdUsername = "TestCreatedUser" + differentiator
+
+	var userDB *mgo.Database
+	var user mgo.User
+
+	operations := map[string]func(db *mgo.Database) error{
+		opFind: func(db *mgo.Database) error {
+			var doc bson.M
+			return db.C(collectionName).Find(nil).One(&doc)
+		},
+		opInsert: func(db *mgo.Database) error {
+			return db.C(collectionName).Insert(bson.M{"n": 2})
+		},
+		opCreateIndex: func(db *mgo.Database) error {
+			return createIndex(db.C(collectionName), "n")
+		},
+		opCreateUser: func(db *mgo.Database) error {
+			return db.UpsertUser(&mgo.User{Username: createdUsername, Password: "TestPassword", Roles: []mgo.Role{mgo.RoleRead}})
+		},
+		opServerStatus: func(db *mgo.Database) error {
+			var status bson.M
+			return db.Session.Run("serverStatus", &status)
+		},
+		opDropDatabase: func(db *mgo.Database) error {
+			return db.DropDatabase()
+		},
+	}
+
+	BeforeEach(func() {
+		userDB = nil
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		if config.boundCredentials() {
+			Skip("creating users needs root credentials, mongo_database is set")
+		}
+
+		Expect(rootSession.DB(databaseName).C(collectionName).Insert(bson.M{"n": 1})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		if userDB != nil {
+			Expect(userDB.RemoveUser(user.Username)).To(Succeed())
+			rootSession.DB(databaseName).RemoveUser(createdUsername)
+		}
+		rootSession.DB(databaseName).DropDatabase()
+		rootSession.Close()
+	})
+
+	DescribeTable("should grant exactly the documented operations",
+		func(c roleCase) {
+			source := databaseName
+			if c.cluster {
+				source = "admin"
+			}
+			user = mgo.User{
+				Username: "TestRoleUser" + differentiator,
+				Password: "TestPassword",
+				Roles:    []mgo.Role{c.role},
+			}
+			Expect(rootSession.DB(source).UpsertUser(&user)).To(Succeed())
+			userDB = rootSession.DB(source)
+
+			session := rootSession.New()
+			defer session.Close()
+			session.LogoutAll()
+			Expect(config.login(session, source, user.Username, user.Password)).To(Succeed())
+			db := session.DB(databaseName)
+
+			allowed := map[string]bool{}
+			for _, op := range c.allowed {
+				allowed[op] = true
+			}
+
+			var mismatches []string
+			for _, op := range matrixOperations {
+				err := operations[op](db)
+				outcome := "allowed"
+				switch {
+				case err == nil:
+					if !allowed[op] {
+						mismatches = append(mismatches, fmt.Sprintf("%s should be denied", op))
+					}
+				case unauthorized(err):
+					outcome = "denied"
+					if allowed[op] {
+						mismatches = append(mismatches, fmt.Sprintf("%s should be allowed: %v", op, err))
+					}
+				default:
+					outcome = "failed"
+					mismatches = append(mismatches, fmt.Sprintf("%s failed with a non-authorization error: %v", op, err))
+				}
+				fmt.Fprintf(GinkgoWriter, "%-16s %-14s %s\n", c.role, op, outcome)
+			}
+			Expect(mismatches).To(BeEmpty())
+		},
+		Entry("read", roleCase{role: mgo.RoleRead, allowed: []string{opFind}}),
+		Entry("readWrite", roleCase{role: mgo.RoleReadWrite, allowed: []string{opFind, opInsert, opCreateIndex}}),
+		Entry("dbAdmin", roleCase{role: mgo.RoleDBAdmin, allowed: []string{opCreateIndex, opDropDatabase}}),
+		Entry("userAdmin", roleCase{role: mgo.RoleUserAdmin, allowed: []string{opCreateUser}}),
+		Entry("dbOwner", roleCase{role: "dbOwner",
+			allowed: []string{opFind, opInsert, opCreateIndex, opCreateUser, opDropDatabase}}),
+		Entry("readAnyDatabase", roleCase{role: mgo.RoleReadAny, cluster: true, allowed: []string{opFind}}),
+		Entry("readWriteAnyDatabase", roleCase{role: mgo.RoleReadWriteAny, cluster: true,
+			allowed: []string{opFind, opInsert, opCreateIndex}}),
+		Entry("clusterMonitor", roleCase{role: "clusterMonitor", cluster: true, allowed: []string{opServerStatus}}),
+	)
+})
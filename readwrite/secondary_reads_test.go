@@ -0,0 +1,166 @@
+package readwrite_test
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// memberLag is the time a document took to become visible on a member.
+type memberLag struct {
+	Member string
+	Lag    time.Duration
+	Err    error
+}
+
+// measureLag polls member through its direct session until the document
+// with id is visible in collection, or deadline passes.
+func measureLag(session *mgo.Session, member, database, collection string, id interface{}, writtenAt, deadline time.Time) memberLag {
+	col := session.DB(database).C(collection)
+	for {
+		n, err := col.FindId(id).Count()
+		if err == nil && n == 1 {
+			return memberLag{Member: member, Lag: time.Since(writtenAt)}
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("document not replicated")
+			}
+			return memberLag{Member: member, Lag: time.Since(writtenAt), Err: err}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func lagTable(lags []memberLag) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\nMEMBER\tLAG\tERROR\n")
+	for _, l := range lags {
+		errText := "-"
+		if l.Err != nil {
+			errText = l.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Member, l.Lag, errText)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+var _ = describeGroup(groupReplication, "MongoDB secondary reads", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName string
+	var collectionName = "TestReplication-" + differentiator
+
+	var members []string
+
+	// insertCanary writes a new canary document through the primary and
+	// returns its id and when the write was acknowledged.
+	insertCanary := func() (bson.ObjectId, time.Time) {
+		id := bson.NewObjectId()
+		err := rootSession.DB(databaseName).C(collectionName).Insert(bson.M{"_id": id, "differentiator": differentiator})
+		Expect(err).NotTo(HaveOccurred())
+		return id, time.Now()
+	}
+
+	BeforeEach(func() {
+		if config.MongoReplicaSetName == "" {
+			Skip("mongo_replica_set_name is not set")
+		}
+
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		databaseName = config.workDatabase(differentiator)
+
+		var result isMasterResult
+		Expect(rootSession.Run("isMaster", &result)).To(Succeed())
+		members = append(result.Hosts, result.Passives...)
+		sort.Strings(members)
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		rootSession.DB(databaseName).C(collectionName).DropCollection()
+		rootSession.Close()
+	})
+
+	DescribeTable("should serve the canary document",
+		func(mode mgo.Mode, needsSecondary bool) {
+			if needsSecondary && len(members) < 2 {
+				Skip("the replica set has no secondary")
+			}
+
+			canaryId, _ := insertCanary()
+
+			session := rootSession.Copy()
+			defer session.Close()
+			session.SetMode(mode, true)
+
+			t := config.timeouts()
+			col := session.DB(databaseName).C(collectionName)
+			Eventually(func() (int, error) {
+				return col.FindId(canaryId).Count()
+			}, t.Replication, 10*time.Millisecond).Should(Equal(1))
+		},
+		Entry("in Secondary mode", mgo.Secondary, true),
+		Entry("in SecondaryPreferred mode", mgo.SecondaryPreferred, false),
+		Entry("in Nearest mode", mgo.Nearest, false),
+	)
+
+	It("should replicate the canary document to every member within the lag bound", func() {
+		// Members are dialed before the canary is written so that
+		// connecting is not counted as lag.
+		memberSessions := make([]*mgo.Session, len(members))
+		memberErrs := make([]error, len(members))
+		for i, member := range members {
+			session, err := config.dialDirect(member)
+			if err == nil {
+				defer session.Close()
+				err = session.Ping()
+				memberSessions[i] = session
+			}
+			memberErrs[i] = err
+		}
+
+		canaryId, writtenAt := insertCanary()
+		t := config.timeouts()
+		deadline := writtenAt.Add(t.Replication)
+
+		lags := make([]memberLag, len(members))
+		done := make(chan struct{})
+		for i, member := range members {
+			go func(i int, member string) {
+				defer GinkgoRecover()
+				if memberErrs[i] != nil {
+					lags[i] = memberLag{Member: member, Err: memberErrs[i]}
+				} else {
+					lags[i] = measureLag(memberSessions[i], member, databaseName, collectionName, canaryId, writtenAt, deadline)
+				}
+				done <- struct{}{}
+			}(i, member)
+		}
+		for range members {
+			<-done
+		}
+
+		fmt.Fprintf(GinkgoWriter, "replication lag per member:%s", lagTable(lags))
+		for _, l := range lags {
+			Expect(l.Err).NotTo(HaveOccurred(), lagTable(lags))
+			Expect(l.Lag).To(BeNumerically("<=", t.Replication), lagTable(lags))
+		}
+	})
+})
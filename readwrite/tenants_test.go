@@ -0,0 +1,113 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// tenant is a database provisioned with its own user, as a broker does
+// for each service instance.
+type tenant struct {
+	database string
+	user     mgo.User
+	session  *mgo.Session
+}
+
+var _ = describeGroup(groupAuth, "MongoDB tenant isolation", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var tenants []*tenant
+
+	operations := []struct {
+		name string
+		run  func(db *mgo.Database) error
+	}{
+		{"read", func(db *mgo.Database) error {
+			var doc bson.M
+			return db.C("TestCollection").Find(nil).One(&doc)
+		}},
+		{"write", func(db *mgo.Database) error {
+			return db.C("TestCollection").Insert(bson.M{"n": 2})
+		}},
+		{"createIndex", func(db *mgo.Database) error {
+			return createIndex(db.C("TestCollection"), "n")
+		}},
+		{"usersInfo", func(db *mgo.Database) error {
+			var result bson.M
+			return db.Run(bson.M{"usersInfo": 1}, &result)
+		}},
+	}
+
+	BeforeEach(func() {
+		tenants = nil
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		if config.boundCredentials() {
+			Skip("provisioning tenants needs root credentials, mongo_database is set")
+		}
+
+		for i := 0; i < 2; i++ {
+			differentiator := uuid.NewV4().String()
+			t := &tenant{
+				database: "TestDatabase-" + differentiator,
+				user: mgo.User{
+					Username: "TestTenant" + differentiator,
+					Password: "TestPassword",
+					Roles:    []mgo.Role{"dbOwner"},
+				},
+			}
+			tenants = append(tenants, t)
+
+			db := rootSession.DB(t.database)
+			Expect(db.UpsertUser(&t.user)).To(Succeed())
+			Expect(db.C("TestCollection").Insert(bson.M{"n": 1})).To(Succeed())
+
+			t.session = rootSession.New()
+			t.session.LogoutAll()
+			Expect(config.login(t.session, t.database, t.user.Username, t.user.Password)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		for _, t := range tenants {
+			if t.session != nil {
+				t.session.Close()
+			}
+			db := rootSession.DB(t.database)
+			Expect(db.RemoveUser(t.user.Username)).To(Succeed())
+			db.DropDatabase()
+		}
+		rootSession.Close()
+	})
+
+	It("should confine each tenant user to its own database", func() {
+		var leaks []string
+		for _, t := range tenants {
+			for _, other := range tenants {
+				own := t == other
+				for _, op := range operations {
+					err := op.run(t.session.DB(other.database))
+					switch {
+					case own && err != nil:
+						leaks = append(leaks, fmt.Sprintf("%s cannot %s its own database: %v", t.user.Username, op.name, err))
+					case !own && err == nil:
+						leaks = append(leaks, fmt.Sprintf("%s can %s %s", t.user.Username, op.name, other.database))
+					case !own && !unauthorized(err):
+						leaks = append(leaks, fmt.Sprintf("%s %s on %s failed with a non-authorization error: %v",
+							t.user.Username, op.name, other.database, err))
+					}
+				}
+			}
+		}
+		Expect(leaks).To(BeEmpty())
+	})
+})
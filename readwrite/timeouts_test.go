@@ -0,0 +1,107 @@
+package readwrite_test
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+// Base timeouts per operation class, before timeout_scale is applied.
+// mongo_connect_timeout_ms, mongo_socket_timeout_ms,
+// failover_max_election_ms and max_replication_lag_ms replace the matching
+// base value and are scaled as well.
+const (
+	baseDialTimeout          = 10 * time.Second
+	baseSocketTimeout        = 30 * time.Second
+	baseSyncTimeout          = 30 * time.Second
+	baseEventuallyTimeout    = 10 * time.Second
+	baseConsistentlyDuration = 2 * time.Second
+	baseElectionThreshold    = 30 * time.Second
+	baseReplicationLag       = 10 * time.Second
+
+	// The TTL monitor wakes up every 60 seconds.
+	baseTTLReaping = 2 * time.Minute
+)
+
+type timeouts struct {
+	Dial         time.Duration
+	Socket       time.Duration
+	Sync         time.Duration
+	Eventually   time.Duration
+	Consistently time.Duration
+	Election     time.Duration
+	Replication  time.Duration
+}
+
+// scale returns timeout_scale, treating an unset value as 1.
+func (c testConfig) scale() float64 {
+	if c.TimeoutScale <= 0 {
+		return 1
+	}
+	return c.TimeoutScale
+}
+
+// scaled applies timeout_scale to d. Specs use it for any ad hoc deadline.
+func (c testConfig) scaled(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * c.scale())
+}
+
+// timeouts returns the effective timeout of every operation class.
+func (c testConfig) timeouts() timeouts {
+	dial, socket := baseDialTimeout, baseSocketTimeout
+	if c.MongoConnectTimeoutMS > 0 {
+		dial = time.Duration(c.MongoConnectTimeoutMS) * time.Millisecond
+	}
+	if c.MongoSocketTimeoutMS > 0 {
+		socket = time.Duration(c.MongoSocketTimeoutMS) * time.Millisecond
+	}
+	election := baseElectionThreshold
+	if c.FailoverMaxElectionMS > 0 {
+		election = time.Duration(c.FailoverMaxElectionMS) * time.Millisecond
+	}
+	replication := baseReplicationLag
+	if c.MaxReplicationLagMS > 0 {
+		replication = time.Duration(c.MaxReplicationLagMS) * time.Millisecond
+	}
+
+	return timeouts{
+		Dial:         c.scaled(dial),
+		Socket:       c.scaled(socket),
+		Sync:         c.scaled(baseSyncTimeout),
+		Eventually:   c.scaled(baseEventuallyTimeout),
+		Consistently: c.scaled(baseConsistentlyDuration),
+		Election:     c.scaled(election),
+		Replication:  c.scaled(replication),
+	}
+}
+
+func (t timeouts) String() string {
+	return fmt.Sprintf("dial %s, socket %s, sync %s, eventually %s, consistently %s, election %s, replication lag %s",
+		t.Dial, t.Socket, t.Sync, t.Eventually, t.Consistently, t.Election, t.Replication)
+}
+
+var _ = Describe("Timeout scaling", func() {
+
+	It("should scale the base timeouts", func() {
+		t := testConfig{TimeoutScale: 2.5}.timeouts()
+		Expect(t.Dial).To(Equal(25 * time.Second))
+		Expect(t.Socket).To(Equal(75 * time.Second))
+		Expect(t.Sync).To(Equal(75 * time.Second))
+		Expect(t.Eventually).To(Equal(25 * time.Second))
+		Expect(t.Consistently).To(Equal(5 * time.Second))
+		Expect(t.Election).To(Equal(75 * time.Second))
+		Expect(t.Replication).To(Equal(25 * time.Second))
+	})
+
+	It("should scale timeouts given in the configuration", func() {
+		t := testConfig{TimeoutScale: 2, MongoConnectTimeoutMS: 1500, MongoSocketTimeoutMS: 4000, FailoverMaxElectionMS: 5000}.timeouts()
+		Expect(t.Dial).To(Equal(3 * time.Second))
+		Expect(t.Socket).To(Equal(8 * time.Second))
+		Expect(t.Election).To(Equal(10 * time.Second))
+	})
+
+	It("should treat an unset scale as 1", func() {
+		Expect(testConfig{}.timeouts().Dial).To(Equal(baseDialTimeout))
+	})
+})
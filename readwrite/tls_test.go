@@ -0,0 +1,157 @@
+package readwrite_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+// tlsOptionsSet reports whether any mongo_tls_* option is set.
+func (c testConfig) tlsOptionsSet() bool {
+	return c.MongoTLSCAFile != "" || c.MongoTLSCertFile != "" || c.MongoTLSKeyFile != "" ||
+		c.MongoTLSServerName != "" || c.MongoTLSInsecure || c.MongoTLSRequired
+}
+
+// tlsConfig builds the client TLS configuration from the mongo_tls_*
+// options. The system roots are used unless mongo_tls_ca_file is set.
+func (c testConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         c.MongoTLSServerName,
+		InsecureSkipVerify: c.MongoTLSInsecure,
+	}
+
+	if c.MongoTLSCAFile != "" {
+		pem, err := ioutil.ReadFile(c.MongoTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mongo_tls_ca_file: %s", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mongo_tls_ca_file: no PEM certificate found in %s", c.MongoTLSCAFile)
+		}
+	}
+
+	if c.MongoTLSCertFile != "" || c.MongoTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.MongoTLSCertFile, c.MongoTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mongo_tls_cert_file/mongo_tls_key_file: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// validateTLS appends the TLS option problems to problems.
+func (c testConfig) validateTLS(addf func(format string, args ...interface{})) {
+	if !c.MongoTLS {
+		if c.tlsOptionsSet() {
+			addf("mongo_tls_* options are set but mongo_tls is false")
+		}
+		return
+	}
+
+	if (c.MongoTLSCertFile == "") != (c.MongoTLSKeyFile == "") {
+		addf("mongo_tls_cert_file and mongo_tls_key_file must be given together")
+	}
+	if c.MongoTLSInsecure && (c.MongoTLSCAFile != "" || c.MongoTLSServerName != "") {
+		addf("mongo_tls_insecure_skip_verify is mutually exclusive with mongo_tls_ca_file and mongo_tls_server_name")
+	}
+	if _, err := c.tlsConfig(); err != nil {
+		addf("%s", err)
+	}
+}
+
+// tlsDialer returns a DialServer function opening TLS connections within
+// the dial timeout. Without mongo_tls_server_name, each server certificate
+// is verified against the host name of its own address.
+func (c testConfig) tlsDialer() (func(addr *mgo.ServerAddr) (net.Conn, error), error) {
+	cfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: c.timeouts().Dial}
+	return func(addr *mgo.ServerAddr) (net.Conn, error) {
+		return tls.DialWithDialer(dialer, "tcp", addr.String(), cfg)
+	}, nil
+}
+
+// plaintextAccepted reports whether the member at addr answers isMaster
+// over an unauthenticated plaintext connection.
+func (c testConfig) plaintextAccepted(addr string) (bool, error) {
+	info, err := c.dialInfo()
+	if err != nil {
+		return false, err
+	}
+	info.Addrs = []string{addr}
+	info.Direct = true
+	info.DialServer = nil
+	info.FailFast = true
+	info.Username, info.Password, info.Source, info.Mechanism = "", "", "", ""
+
+	session, err := mgo.DialWithInfo(info)
+	if err != nil {
+		return false, nil
+	}
+	defer session.Close()
+	return session.Run("isMaster", &bson.M{}) == nil, nil
+}
+
+var _ = describeGroup(groupAuth, "MongoDB TLS", func() {
+
+	BeforeEach(func() {
+		if !config.MongoTLS {
+			Skip("mongo_tls is not enabled")
+		}
+	})
+
+	It("should negotiate TLS on every connection", func() {
+		info, err := config.dialInfo()
+		Expect(err).NotTo(HaveOccurred())
+
+		var mu sync.Mutex
+		var states []tls.ConnectionState
+		dialServer := info.DialServer
+		info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			conn, err := dialServer(addr)
+			if err == nil {
+				mu.Lock()
+				states = append(states, conn.(*tls.Conn).ConnectionState())
+				mu.Unlock()
+			}
+			return conn, err
+		}
+
+		session, err := mgo.DialWithInfo(info)
+		Expect(err).NotTo(HaveOccurred())
+		defer session.Close()
+		Expect(session.Ping()).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(states).NotTo(BeEmpty())
+		for _, state := range states {
+			Expect(state.HandshakeComplete).To(BeTrue())
+		}
+	})
+
+	It("should refuse plaintext connections when TLS is required", func() {
+		if !config.MongoTLSRequired {
+			Skip("mongo_tls_required is not set")
+		}
+
+		for _, addr := range config.addrs() {
+			accepted, err := config.plaintextAccepted(addr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accepted).To(BeFalse(), "%s accepted a plaintext connection", addr)
+		}
+	})
+})
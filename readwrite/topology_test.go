@@ -0,0 +1,133 @@
+package readwrite_test
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"text/tabwriter"
+	"time"
+)
+
+type isMasterResult struct {
+	IsMaster bool     `bson:"ismaster"`
+	SetName  string   `bson:"setName"`
+	Primary  string   `bson:"primary"`
+	Hosts    []string `bson:"hosts"`
+	Arbiters []string `bson:"arbiters"`
+	Passives []string `bson:"passives"`
+}
+
+type replSetMember struct {
+	Name       string    `bson:"name"`
+	Health     float64   `bson:"health"`
+	State      int       `bson:"state"`
+	StateStr   string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+	Self       bool      `bson:"self"`
+}
+
+type replSetStatus struct {
+	Set     string          `bson:"set"`
+	Members []replSetMember `bson:"members"`
+}
+
+func replSetGetStatus(session *mgo.Session) (status replSetStatus, err error) {
+	err = session.DB("admin").Run(bson.M{"replSetGetStatus": 1}, &status)
+	return
+}
+
+// primary returns the member in PRIMARY state, if any.
+func (s replSetStatus) primary() *replSetMember {
+	for i := range s.Members {
+		if s.Members[i].StateStr == "PRIMARY" {
+			return &s.Members[i]
+		}
+	}
+	return nil
+}
+
+// table renders the member states, for failure messages.
+func (s replSetStatus) table() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\nset %s\nMEMBER\tSTATE\tHEALTH\tOPTIME\n", s.Set)
+	for _, m := range s.Members {
+		fmt.Fprintf(w, "%s\t%s\t%g\t%s\n", m.Name, m.StateStr, m.Health, m.OptimeDate.Format(time.RFC3339))
+	}
+	w.Flush()
+	return buf.String()
+}
+
+var _ = describeGroup(groupReplication, "MongoDB replica set topology", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	BeforeEach(func() {
+		if config.MongoReplicaSetName == "" {
+			Skip("mongo_replica_set_name is not set")
+		}
+
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if rootSession != nil {
+			rootSession.Close()
+		}
+	})
+
+	It("should report the configured set name and a primary", func() {
+		var result isMasterResult
+		Expect(rootSession.Run("isMaster", &result)).To(Succeed())
+
+		Expect(result.SetName).To(Equal(config.MongoReplicaSetName))
+		Expect(result.Primary).NotTo(BeEmpty())
+	})
+
+	Context("When the member states are visible", func() {
+
+		var status replSetStatus
+
+		BeforeEach(func() {
+			if config.boundCredentials() {
+				Skip("replSetGetStatus needs the clusterMonitor role, mongo_database is set")
+			}
+
+			status, err = replSetGetStatus(rootSession)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should match the configured set name", func() {
+			Expect(status.Set).To(Equal(config.MongoReplicaSetName), status.table())
+		})
+
+		It("should have exactly one primary", func() {
+			primaries := 0
+			for _, m := range status.Members {
+				if m.StateStr == "PRIMARY" {
+					primaries++
+				}
+			}
+			Expect(primaries).To(Equal(1), status.table())
+		})
+
+		It("should have the expected number of members", func() {
+			if config.MongoReplicaSetMembers == 0 {
+				Skip("mongo_replica_set_members is not set")
+			}
+			Expect(status.Members).To(HaveLen(config.MongoReplicaSetMembers), status.table())
+		})
+
+		It("should have every member healthy", func() {
+			for _, m := range status.Members {
+				Expect([]string{"PRIMARY", "SECONDARY", "ARBITER"}).To(ContainElement(m.StateStr), status.table())
+				Expect(m.Health).To(Equal(1.0), status.table())
+			}
+		})
+	})
+})
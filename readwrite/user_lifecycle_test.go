@@ -0,0 +1,137 @@
+package readwrite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// changeRoles runs grantRolesToUser or revokeRolesFromUser for username
+// in db.
+func changeRoles(db *mgo.Database, command, username string, roles ...mgo.Role) error {
+	return db.Run(bson.D{
+		{Name: command, Value: username},
+		{Name: "roles", Value: roles},
+	}, nil)
+}
+
+var _ = describeGroup(groupAuth, "MongoDB user lifecycle", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName = "TestDatabase-" + differentiator
+	var db *mgo.Database
+
+	var user mgo.User
+
+	// liveSession returns a session logged in as user that keeps its
+	// socket, so later operations run on the authenticated connection.
+	liveSession := func(password string) *mgo.Session {
+		session := rootSession.New()
+		session.SetMode(mgo.Strong, true)
+		session.LogoutAll()
+		Expect(config.login(session, databaseName, user.Username, password)).To(Succeed())
+		return session
+	}
+
+	BeforeEach(func() {
+		db = nil
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		if config.boundCredentials() {
+			Skip("managing users needs root credentials, mongo_database is set")
+		}
+
+		user = mgo.User{
+			Username: "TestLifecycleUser" + differentiator,
+			Password: "TestPassword",
+			Roles:    []mgo.Role{mgo.RoleRead},
+		}
+		db = rootSession.DB(databaseName)
+		Expect(db.UpsertUser(&user)).To(Succeed())
+		Expect(db.C("TestCollection").Insert(bson.M{"n": 1})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		if db != nil {
+			Expect(db.RemoveUser(user.Username)).To(Succeed())
+			db.DropDatabase()
+		}
+		rootSession.Close()
+	})
+
+	Context("When the password is rotated", func() {
+
+		var oldPassword string
+		var session *mgo.Session
+
+		BeforeEach(func() {
+			oldPassword = user.Password
+			session = liveSession(oldPassword)
+
+			user.Password = "TestRotatedPassword"
+			Expect(db.UpsertUser(&user)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			if session != nil {
+				session.Close()
+			}
+		})
+
+		// MongoDB does not log out connections authenticated before the
+		// change. mgo replays the old credentials on any new socket though,
+		// so only the reserved one keeps working.
+		It("should keep existing authenticated connections working", func() {
+			var doc bson.M
+			Expect(session.DB(databaseName).C("TestCollection").Find(nil).One(&doc)).To(Succeed())
+		})
+
+		It("should reject the old password", func() {
+			fresh := rootSession.New()
+			defer fresh.Close()
+			fresh.LogoutAll()
+
+			err := config.login(fresh, databaseName, user.Username, oldPassword)
+			Expect(err).To(HaveOccurred())
+			Expect(accessFailure(err)).To(Equal(failureAuthentication), "unexpected error %v", err)
+		})
+
+		It("should accept the new password", func() {
+			fresh := liveSession(user.Password)
+			defer fresh.Close()
+
+			users, err := authenticatedAs(fresh)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(users).To(ConsistOf(user.Username + "@" + databaseName))
+		})
+	})
+
+	It("should apply role grants and revokes to a live session", func() {
+		session := liveSession(user.Password)
+		defer session.Close()
+		col := session.DB(databaseName).C("TestCollection")
+
+		insert := func() error {
+			return col.Insert(bson.M{"n": 2})
+		}
+
+		err := insert()
+		Expect(unauthorized(err)).To(BeTrue(), "insert as %s should be unauthorized, got %v", mgo.RoleRead, err)
+
+		Expect(changeRoles(db, "grantRolesToUser", user.Username, mgo.RoleReadWrite)).To(Succeed())
+		Eventually(insert).Should(Succeed(), "the %s grant did not reach the live session", mgo.RoleReadWrite)
+
+		Expect(changeRoles(db, "revokeRolesFromUser", user.Username, mgo.RoleReadWrite)).To(Succeed())
+		Eventually(func() bool {
+			return unauthorized(insert())
+		}).Should(BeTrue(), "the %s revoke did not reach the live session", mgo.RoleReadWrite)
+	})
+})
@@ -0,0 +1,288 @@
+package readwrite_test
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strconv"
+	"strings"
+)
+
+type vcapService struct {
+	Name        string                 `json:"name"`
+	Label       string                 `json:"label"`
+	Tags        []string               `json:"tags"`
+	Credentials map[string]interface{} `json:"credentials"`
+}
+
+// matches reports whether the service is the one selected by mongo_service,
+// or looks like a MongoDB service when no selector is configured.
+func (s vcapService) matches(selector string) bool {
+	if selector != "" {
+		if s.Name == selector || s.Label == selector {
+			return true
+		}
+		for _, tag := range s.Tags {
+			if tag == selector {
+				return true
+			}
+		}
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(s.Label), "mongo") {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if strings.Contains(strings.ToLower(tag), "mongo") {
+			return true
+		}
+	}
+	return false
+}
+
+// findVCAPService returns the single bound service selected by selector.
+func findVCAPService(vcap, selector string) (*vcapService, error) {
+	var services map[string][]vcapService
+	if err := json.Unmarshal([]byte(vcap), &services); err != nil {
+		return nil, fmt.Errorf("invalid VCAP_SERVICES: %s", err)
+	}
+
+	var found []vcapService
+	for _, instances := range services {
+		for _, s := range instances {
+			if s.matches(selector) {
+				found = append(found, s)
+			}
+		}
+	}
+
+	what := "a MongoDB service"
+	if selector != "" {
+		what = fmt.Sprintf("service %q", selector)
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("VCAP_SERVICES has no binding for %s", what)
+	case 1:
+		return &found[0], nil
+	default:
+		var names []string
+		for _, s := range found {
+			names = append(names, s.Name)
+		}
+		return nil, fmt.Errorf("VCAP_SERVICES has several bindings for %s (%s), set mongo_service to pick one",
+			what, strings.Join(names, ", "))
+	}
+}
+
+// applyVCAPService overrides the connection fields of c with the
+// credentials of a bound service. The binding user is assumed to be
+// confined to its database, so mongo_database is always set. The database
+// and auth source come from the credentials only, never from the file.
+func (c *testConfig) applyVCAPService(service *vcapService) error {
+	creds := vcapCredentials(service.Credentials)
+	c.MongoDatabase, c.MongoAuthSource = "", ""
+
+	// The URI carries the full seed list, whereas brokers sending both
+	// often give a single host in the discrete keys.
+	uri := creds.str("uri", "url")
+	if uri != "" {
+		if err := c.applyMongoURI(uri); err != nil {
+			return fmt.Errorf("service %q: %s", service.Name, err)
+		}
+	} else {
+		c.applyVCAPDiscreteCredentials(creds)
+	}
+
+	c.MongoDatabase = creds.str("database", "db")
+	if c.MongoDatabase == "" && uri != "" {
+		c.MongoDatabase, _ = mongoURIDatabase(uri)
+	}
+	if c.MongoDatabase == "" {
+		return fmt.Errorf("service %q: credentials name no database", service.Name)
+	}
+	if c.MongoAuthSource == "" {
+		c.MongoAuthSource = c.MongoDatabase
+	}
+
+	return nil
+}
+
+// applyVCAPDiscreteCredentials overrides the connection fields of c with
+// the individual credentials keys.
+func (c *testConfig) applyVCAPDiscreteCredentials(creds vcapCredentials) {
+	if hosts := creds.list("hosts"); len(hosts) > 0 {
+		c.MongoHosts, c.MongoHost = hosts, ""
+	} else if host := creds.str("host", "hostname"); host != "" {
+		c.MongoHosts, c.MongoHost = nil, host
+	}
+	if port := creds.str("port"); port != "" {
+		c.MongoPort = port
+	}
+	if username := creds.str("username", "user"); username != "" {
+		c.MongoRoot = username
+	}
+	if password := creds.str("password"); password != "" {
+		c.MongoRootPassword = password
+	}
+	if replicaSet := creds.str("replica_set", "replicaSet"); replicaSet != "" {
+		c.MongoReplicaSetName = replicaSet
+	}
+	c.MongoAuthSource = creds.str("auth_source", "authSource")
+}
+
+// vcapCredentials gives lenient access to a credentials block, whose value
+// types vary between brokers (ports as numbers or strings, hosts as arrays
+// or comma-separated strings, with or without ports).
+type vcapCredentials map[string]interface{}
+
+func (c vcapCredentials) str(keys ...string) string {
+	for _, key := range keys {
+		switch v := c[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+func (c vcapCredentials) list(key string) []string {
+	var hosts []string
+	switch v := c[key].(type) {
+	case string:
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
+	case []interface{}:
+		for _, h := range v {
+			if s, ok := h.(string); ok && s != "" {
+				hosts = append(hosts, s)
+			}
+		}
+	}
+	return hosts
+}
+
+var _ = Describe("VCAP_SERVICES binding", func() {
+
+	const vcap = `{
+		"user-provided": [
+			{"name": "logs", "label": "user-provided", "tags": [], "credentials": {"uri": "syslog://logs"}}
+		],
+		"mongodb": [
+			{
+				"name": "my-mongo",
+				"label": "mongodb",
+				"tags": ["mongodb", "document"],
+				"credentials": {
+					"hosts": ["10.0.0.1", "10.0.0.2:27018"],
+					"port": 27017,
+					"username": "binding-user",
+					"password": "binding-password",
+					"database": "tenant-db",
+					"replica_set": "rs0"
+				}
+			}
+		]
+	}`
+
+	It("should extract credentials from the MongoDB service", func() {
+		service, err := findVCAPService(vcap, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg := testConfig{MongoRoot: "root", MongoRootPassword: "toto"}
+		Expect(cfg.applyVCAPService(service)).To(Succeed())
+
+		Expect(cfg.addrs()).To(Equal([]string{"10.0.0.1:27017", "10.0.0.2:27018"}))
+		Expect(cfg.MongoRoot).To(Equal("binding-user"))
+		Expect(cfg.MongoRootPassword).To(Equal("binding-password"))
+		Expect(cfg.MongoDatabase).To(Equal("tenant-db"))
+		Expect(cfg.MongoAuthSource).To(Equal("tenant-db"))
+		Expect(cfg.MongoReplicaSetName).To(Equal("rs0"))
+	})
+
+	It("should select a service by name, label or tag", func() {
+		for _, selector := range []string{"my-mongo", "mongodb", "document"} {
+			service, err := findVCAPService(vcap, selector)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(service.Name).To(Equal("my-mongo"))
+		}
+	})
+
+	It("should prefer the credentials URI and take the database from it", func() {
+		service, err := findVCAPService(`{"mongodb": [{"name": "m", "label": "mongodb", "credentials": {
+			"uri": "mongodb://u:p@h1,h2/tenant?replicaSet=rs1"}}]}`, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg := testConfig{}
+		Expect(cfg.applyVCAPService(service)).To(Succeed())
+
+		Expect(cfg.addrs()).To(Equal([]string{"h1:27017", "h2:27017"}))
+		Expect(cfg.MongoRoot).To(Equal("u"))
+		Expect(cfg.MongoDatabase).To(Equal("tenant"))
+		Expect(cfg.MongoReplicaSetName).To(Equal("rs1"))
+	})
+
+	It("should ignore the discrete keys when a URI is given", func() {
+		service, err := findVCAPService(`{"mongodb": [{"name": "m", "label": "mongodb", "credentials": {
+			"uri": "mongodb://u:p@h1,h2,h3/tenant?replicaSet=rs1",
+			"host": "h1", "hostname": "h1", "port": "27018",
+			"username": "other", "password": "other-password", "replica_set": "rs9"}}]}`, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg := testConfig{}
+		Expect(cfg.applyVCAPService(service)).To(Succeed())
+
+		Expect(cfg.addrs()).To(Equal([]string{"h1:27017", "h2:27017", "h3:27017"}))
+		Expect(cfg.MongoRoot).To(Equal("u"))
+		Expect(cfg.MongoRootPassword).To(Equal("p"))
+		Expect(cfg.MongoReplicaSetName).To(Equal("rs1"))
+	})
+
+	It("should take the database and auth source from the credentials only", func() {
+		service, err := findVCAPService(vcap, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg := testConfig{MongoAuthSource: "admin", MongoDatabase: "file-db"}
+		Expect(cfg.applyVCAPService(service)).To(Succeed())
+		Expect(cfg.MongoDatabase).To(Equal("tenant-db"))
+		Expect(cfg.MongoAuthSource).To(Equal("tenant-db"))
+
+		service, err = findVCAPService(`{"mongodb": [{"name": "m", "label": "mongodb", "credentials": {
+			"uri": "mongodb://u:p@h1/tenant?authSource=users"}}]}`, "")
+		Expect(err).NotTo(HaveOccurred())
+		cfg = testConfig{MongoAuthSource: "admin"}
+		Expect(cfg.applyVCAPService(service)).To(Succeed())
+		Expect(cfg.MongoDatabase).To(Equal("tenant"))
+		Expect(cfg.MongoAuthSource).To(Equal("users"))
+	})
+
+	It("should fail when the credentials name no database", func() {
+		for _, creds := range []string{`{"uri": "mongodb://u:p@h1"}`, `{"uri": "mongodb://u:p@h1/?authSource=admin"}`, `{"host": "h1"}`} {
+			service, err := findVCAPService(`{"mongodb": [{"name": "m", "label": "mongodb", "credentials": `+creds+`}]}`, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			cfg := testConfig{MongoAuthSource: "admin", MongoDatabase: "file-db"}
+			Expect(cfg.applyVCAPService(service)).To(MatchError(ContainSubstring("credentials name no database")), creds)
+		}
+	})
+
+	It("should fail when no MongoDB service is bound", func() {
+		_, err := findVCAPService(`{"user-provided": [{"name": "logs", "label": "user-provided"}]}`, "")
+		Expect(err).To(MatchError(ContainSubstring("no binding for a MongoDB service")))
+	})
+
+	It("should fail when the selection is ambiguous", func() {
+		_, err := findVCAPService(`{"mongodb": [{"name": "a", "label": "mongodb"}, {"name": "b", "label": "mongodb"}]}`, "")
+		Expect(err).To(MatchError(ContainSubstring("several bindings")))
+	})
+})
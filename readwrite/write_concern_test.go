@@ -0,0 +1,102 @@
+package readwrite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+// Server error codes returned when a write concern cannot be honoured.
+const (
+	codeWriteConcernFailed        = 64
+	codeUnsatisfiableWriteConcern = 100
+)
+
+var _ = describeGroup(groupCRUD, "MongoDB write concerns", func() {
+
+	var rootSession *mgo.Session
+	var err error
+
+	var differentiator = uuid.NewV4().String()
+	var databaseName string
+	var collectionName = "TestWriteConcern-" + differentiator
+
+	BeforeEach(func() {
+		rootSession, err = config.dial()
+		Expect(err).NotTo(HaveOccurred())
+		databaseName = config.workDatabase(differentiator)
+	})
+
+	AfterEach(func() {
+		if rootSession == nil {
+			return
+		}
+		rootSession.SetSafe(&mgo.Safe{})
+		rootSession.DB(databaseName).C(collectionName).DropCollection()
+		rootSession.Close()
+	})
+
+	wtimeout := func() int {
+		return int(config.scaled(2*time.Second) / time.Millisecond)
+	}
+
+	DescribeTable("should insert, update and remove a document",
+		func(safe func() *mgo.Safe) {
+			session := rootSession.Copy()
+			defer session.Close()
+			session.SetSafe(safe())
+			col := session.DB(databaseName).C(collectionName)
+
+			// Reads go through the root session, which always waits for
+			// acknowledgement, so unacknowledged writes are polled for.
+			reader := rootSession.DB(databaseName).C(collectionName)
+			expectCount := func(query bson.M, n int) {
+				count := func() (int, error) { return reader.Find(query).Count() }
+				if safe() != nil {
+					Expect(count()).To(Equal(n))
+				} else {
+					Eventually(count).Should(Equal(n))
+				}
+			}
+
+			id := bson.NewObjectId()
+			Expect(col.Insert(bson.M{"_id": id, "Name": "some-item"})).To(Succeed())
+			expectCount(bson.M{"_id": id, "Name": "some-item"}, 1)
+
+			Expect(col.UpdateId(id, bson.M{"$set": bson.M{"Name": "Pierre"}})).To(Succeed())
+			expectCount(bson.M{"_id": id, "Name": "Pierre"}, 1)
+
+			Expect(col.RemoveId(id)).To(Succeed())
+			expectCount(bson.M{"_id": id}, 0)
+		},
+		Entry("unacknowledged", func() *mgo.Safe { return nil }),
+		Entry("w:1", func() *mgo.Safe { return &mgo.Safe{W: 1} }),
+		Entry("w:majority", func() *mgo.Safe { return &mgo.Safe{WMode: "majority"} }),
+		Entry("journaled", func() *mgo.Safe { return &mgo.Safe{J: true} }),
+		Entry("w:majority with wtimeout", func() *mgo.Safe { return &mgo.Safe{WMode: "majority", WTimeout: wtimeout()} }),
+	)
+
+	It("should report an unsatisfiable w value instead of hanging", func() {
+		if config.MongoReplicaSetName == "" {
+			Skip("mongo_replica_set_name is not set, w > 1 is rejected by standalone servers")
+		}
+
+		session := rootSession.Copy()
+		defer session.Close()
+		session.SetSafe(&mgo.Safe{W: 50, WTimeout: wtimeout()})
+
+		started := time.Now()
+		err := session.DB(databaseName).C(collectionName).Insert(bson.M{"Name": "some-item"})
+		elapsed := time.Since(started)
+
+		Expect(err).To(HaveOccurred())
+		lastErr, ok := err.(*mgo.LastError)
+		Expect(ok).To(BeTrue(), "unexpected error %#v", err)
+		Expect([]int{codeWriteConcernFailed, codeUnsatisfiableWriteConcern}).To(ContainElement(lastErr.Code), lastErr.Err)
+		Expect(elapsed).To(BeNumerically("<", config.timeouts().Socket))
+	})
+})